@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Jason-cqtan/simple-blog/models"
+	"github.com/Jason-cqtan/simple-blog/utils"
+	"gorm.io/gorm"
+)
+
+var ErrInvalidInvite = errors.New("invalid, expired or used-up invite code")
+
+// CreateInvite stores a new invite and returns the plain code, which is not
+// recoverable afterwards.
+func CreateInvite(db *gorm.DB, userID uint, note string, maxUses int, expiresAt time.Time) (string, *models.Invite, error) {
+	code, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return "", nil, err
+	}
+
+	invite := &models.Invite{
+		CreatedByID: userID,
+		CodeHash:    utils.HashToken(code),
+		Prefix:      code[:6],
+		Note:        note,
+		MaxUses:     maxUses,
+		ExpiresAt:   expiresAt,
+	}
+	if err := db.Create(invite).Error; err != nil {
+		return "", nil, err
+	}
+	return code, invite, nil
+}
+
+// RedeemInvite uses up one registration of the invite with the given code.
+// Run it in the transaction that creates the account, so the use is given
+// back if registration fails.
+func RedeemInvite(tx *gorm.DB, code string) (*models.Invite, error) {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return nil, ErrInvalidInvite
+	}
+
+	var invite models.Invite
+	if err := tx.Where("code_hash = ?", utils.HashToken(code)).First(&invite).Error; err != nil {
+		return nil, ErrInvalidInvite
+	}
+
+	// The conditions are repeated in the update so two registrations racing
+	// for the last use cannot both succeed.
+	result := tx.Model(&models.Invite{}).
+		Where("id = ? AND uses < max_uses AND revoked_at IS NULL AND expires_at > ?", invite.ID, time.Now()).
+		Update("uses", gorm.Expr("uses + 1"))
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrInvalidInvite
+	}
+	invite.Uses++
+	return &invite, nil
+}
+
+// RevokeInvite revokes an invite. Unless any is set, only the user's own
+// invites can be revoked.
+func RevokeInvite(db *gorm.DB, userID, inviteID uint, any bool) error {
+	query := db.Model(&models.Invite{}).Where("id = ? AND revoked_at IS NULL", inviteID)
+	if !any {
+		query = query.Where("created_by_id = ?", userID)
+	}
+	result := query.Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidInvite
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/Jason-cqtan/simple-blog/models"
+	"github.com/Jason-cqtan/simple-blog/utils"
+	"gorm.io/gorm"
+)
+
+// RecoveryCodeCount is how many recovery codes a user receives at a time.
+const RecoveryCodeCount = 10
+
+// VerifyTOTP checks a TOTP code for user and records its time step, so the
+// same code cannot be used twice.
+func VerifyTOTP(db *gorm.DB, user *models.User, code string) bool {
+	if user.TOTPSecret == "" {
+		return false
+	}
+	step, ok := utils.ValidateTOTP(user.TOTPSecret, code, time.Now(), user.TOTPLastStep)
+	if !ok {
+		return false
+	}
+	result := db.Model(&models.User{}).
+		Where("id = ? AND totp_last_step < ?", user.ID, step).
+		Update("totp_last_step", step)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false
+	}
+	user.TOTPLastStep = step
+	return true
+}
+
+// UseRecoveryCode consumes one of the user's unused recovery codes.
+func UseRecoveryCode(db *gorm.DB, userID uint, code string) bool {
+	code = utils.NormalizeRecoveryCode(code)
+	hashes := []string{utils.HashToken(code)}
+	// Codes issued before normalization dropped the dash were hashed in
+	// their displayed xxxxx-xxxxx form.
+	if len(code) == 10 {
+		hashes = append(hashes, utils.HashToken(code[:5]+"-"+code[5:]))
+	}
+	result := db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash IN ? AND used_at IS NULL", userID, hashes).
+		Update("used_at", time.Now())
+	return result.Error == nil && result.RowsAffected == 1
+}
+
+// VerifySecondFactor accepts either a current TOTP code or an unused
+// recovery code.
+func VerifySecondFactor(db *gorm.DB, user *models.User, code string) bool {
+	return VerifyTOTP(db, user, code) || UseRecoveryCode(db, user.ID, code)
+}
+
+// ReplaceRecoveryCodes discards the user's recovery codes and returns a
+// fresh set. The plain codes are only available from this return value.
+func ReplaceRecoveryCodes(db *gorm.DB, userID uint) ([]string, error) {
+	codes, err := utils.GenerateRecoveryCodes(RecoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		rows := make([]models.RecoveryCode, len(codes))
+		for i, code := range codes {
+			rows[i] = models.RecoveryCode{UserID: userID, CodeHash: utils.HashToken(utils.NormalizeRecoveryCode(code))}
+		}
+		return tx.Create(&rows).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
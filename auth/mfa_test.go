@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Jason-cqtan/simple-blog/internal/testdb"
+	"github.com/Jason-cqtan/simple-blog/models"
+	"github.com/Jason-cqtan/simple-blog/utils"
+)
+
+func TestUseRecoveryCode(t *testing.T) {
+	tests := []struct {
+		name  string
+		typed func(code string) string
+	}{
+		{"as shown", func(code string) string { return code }},
+		{"without the dash", func(code string) string { return strings.ReplaceAll(code, "-", "") }},
+		{"upper case with a space", func(code string) string { return strings.ToUpper(strings.ReplaceAll(code, "-", " ")) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testdb.Open(t, &models.RecoveryCode{})
+			codes, err := ReplaceRecoveryCodes(db, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if UseRecoveryCode(db, 2, tt.typed(codes[0])) {
+				t.Error("another user's code was accepted")
+			}
+			if !UseRecoveryCode(db, 1, tt.typed(codes[0])) {
+				t.Fatal("code was rejected")
+			}
+			if UseRecoveryCode(db, 1, tt.typed(codes[0])) {
+				t.Error("code was accepted twice")
+			}
+		})
+	}
+}
+
+func TestUseRecoveryCodeIssuedWithDash(t *testing.T) {
+	// Codes issued before normalization dropped the dash were hashed as
+	// shown.
+	db := testdb.Open(t, &models.RecoveryCode{})
+	if err := db.Create(&models.RecoveryCode{UserID: 1, CodeHash: utils.HashToken("abcde-fghij")}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !UseRecoveryCode(db, 1, "ABCDEFGHIJ") {
+		t.Error("legacy code was rejected")
+	}
+}
@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"github.com/Jason-cqtan/simple-blog/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// LoadUser returns the user together with their role and its permissions.
+func LoadUser(db *gorm.DB, userID uint) (*models.User, error) {
+	var user models.User
+	if err := db.Preload("Role.Permissions").First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// SetUser stores the user's role, permissions and account state on the
+// request context for Can, later middleware and the templates.
+func SetUser(c *gin.Context, user *models.User) {
+	perms := make(map[string]bool, len(user.Role.Permissions))
+	for _, p := range user.Role.Permissions {
+		perms[p.Name] = true
+	}
+	c.Set("role", user.Role.Name)
+	c.Set("permissions", perms)
+	c.Set("emailVerified", user.EmailVerified())
+}
+
+// Can reports whether the authenticated user holds perm.
+func Can(c *gin.Context, perm string) bool {
+	val, _ := c.Get("permissions")
+	perms, ok := val.(map[string]bool)
+	return ok && perms[perm]
+}
+
+// CanModify reports whether the authenticated user may act on a resource
+// owned by ownerID: either they hold anyPerm, or they own it and hold ownPerm.
+func CanModify(c *gin.Context, ownerID uint, ownPerm, anyPerm string) bool {
+	if Can(c, anyPerm) {
+		return true
+	}
+	userID, _ := c.Get("userID")
+	uid, ok := userID.(uint)
+	return ok && uid == ownerID && Can(c, ownPerm)
+}
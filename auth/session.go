@@ -0,0 +1,184 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Jason-cqtan/simple-blog/config"
+	"github.com/Jason-cqtan/simple-blog/models"
+	"github.com/Jason-cqtan/simple-blog/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	AccessCookie  = "token"
+	RefreshCookie = "refresh_token"
+)
+
+// sessionTouchInterval limits how often a session's last-seen time is
+// written, so busy clients don't update the row on every request.
+const sessionTouchInterval = time.Minute
+
+var ErrInvalidSession = errors.New("invalid or revoked session")
+
+// Tokens is the credential pair handed to a client for one session.
+type Tokens struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// CreateSession stores session, which must have UserID set, and issues its
+// first token pair. The refresh token hash, expiry and last-seen time are
+// filled in here.
+func CreateSession(db *gorm.DB, cfg *config.Config, session *models.Session) (*models.Session, *Tokens, error) {
+	refresh, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	session.RefreshTokenHash = utils.HashToken(refresh)
+	session.ExpiresAt = now.Add(cfg.RefreshTokenTTL)
+	session.LastSeenAt = &now
+	if err := db.Create(session).Error; err != nil {
+		return nil, nil, err
+	}
+
+	tokens, err := issueTokens(cfg, session, refresh)
+	if err != nil {
+		return nil, nil, err
+	}
+	return session, tokens, nil
+}
+
+// RotateSession exchanges a refresh token for a new token pair. The presented
+// refresh token is invalidated, so each one can be used only once.
+func RotateSession(db *gorm.DB, cfg *config.Config, refreshToken string) (*models.Session, *Tokens, error) {
+	if refreshToken == "" {
+		return nil, nil, ErrInvalidSession
+	}
+
+	oldHash := utils.HashToken(refreshToken)
+	var session models.Session
+	if err := db.Where("refresh_token_hash = ?", oldHash).First(&session).Error; err != nil {
+		return nil, nil, ErrInvalidSession
+	}
+	if !session.Active() {
+		return nil, nil, ErrInvalidSession
+	}
+
+	refresh, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, nil, err
+	}
+	session.RefreshTokenHash = utils.HashToken(refresh)
+	session.ExpiresAt = time.Now().Add(cfg.RefreshTokenTTL)
+
+	// Guard on the old hash so two concurrent refreshes cannot both succeed.
+	result := db.Model(&models.Session{}).
+		Where("id = ? AND refresh_token_hash = ?", session.ID, oldHash).
+		Updates(map[string]interface{}{
+			"refresh_token_hash": session.RefreshTokenHash,
+			"expires_at":         session.ExpiresAt,
+		})
+	if result.Error != nil {
+		return nil, nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil, ErrInvalidSession
+	}
+
+	tokens, err := issueTokens(cfg, &session, refresh)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &session, tokens, nil
+}
+
+// ValidateSession checks that the session referenced by claims still exists,
+// belongs to the token's user and has not been revoked or expired.
+func ValidateSession(db *gorm.DB, claims *utils.Claims) (*models.Session, error) {
+	var session models.Session
+	if err := db.First(&session, claims.SessionID).Error; err != nil {
+		return nil, ErrInvalidSession
+	}
+	if session.UserID != claims.UserID || !session.Active() {
+		return nil, ErrInvalidSession
+	}
+	return &session, nil
+}
+
+// TouchSession records that the session was just used from ip.
+func TouchSession(db *gorm.DB, session *models.Session, ip string) {
+	now := time.Now()
+	if session.IP == ip && session.LastSeenAt != nil && now.Sub(*session.LastSeenAt) < sessionTouchInterval {
+		return
+	}
+	db.Model(&models.Session{}).Where("id = ?", session.ID).Updates(map[string]interface{}{
+		"last_seen_at": now,
+		"ip":           ip,
+	})
+	session.LastSeenAt = &now
+	session.IP = ip
+}
+
+// RevokeUserSession ends one of the user's sessions.
+func RevokeUserSession(db *gorm.DB, userID, sessionID uint) error {
+	result := db.Model(&models.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidSession
+	}
+	return nil
+}
+
+// RevokeSession ends a single session.
+func RevokeSession(db *gorm.DB, sessionID uint) error {
+	return db.Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeUserSessions ends every active session belonging to userID.
+func RevokeUserSessions(db *gorm.DB, userID uint) error {
+	return db.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeOtherSessions ends every active session of userID except keepID.
+func RevokeOtherSessions(db *gorm.DB, userID, keepID uint) error {
+	return db.Model(&models.Session{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, keepID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// SetCookies stores the token pair in HTTP-only cookies.
+func SetCookies(c *gin.Context, cfg *config.Config, tokens *Tokens) {
+	c.SetCookie(AccessCookie, tokens.AccessToken, int(cfg.AccessTokenTTL.Seconds()), "/", "", cfg.SecureCookie, true)
+	c.SetCookie(RefreshCookie, tokens.RefreshToken, int(cfg.RefreshTokenTTL.Seconds()), "/", "", cfg.SecureCookie, true)
+}
+
+// ClearCookies removes both auth cookies.
+func ClearCookies(c *gin.Context, cfg *config.Config) {
+	c.SetCookie(AccessCookie, "", -1, "/", "", cfg.SecureCookie, true)
+	c.SetCookie(RefreshCookie, "", -1, "/", "", cfg.SecureCookie, true)
+}
+
+func issueTokens(cfg *config.Config, session *models.Session, refresh string) (*Tokens, error) {
+	access, err := utils.GenerateToken(session.UserID, session.ID, session.MFA, cfg.Keyring, cfg.AccessTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &Tokens{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		ExpiresIn:    int(cfg.AccessTokenTTL.Seconds()),
+	}, nil
+}
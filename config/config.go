@@ -4,21 +4,130 @@ import (
 	"bufio"
 	"log"
 	"os"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/Jason-cqtan/simple-blog/utils"
 )
 
 type Config struct {
-	DBDriver     string
-	DBHost       string
-	DBPort       string
-	DBUser       string
-	DBPassword   string
-	DBName       string
-	JWTSecret    string
-	ServerPort   string
-	SecureCookie bool
+	DBDriver        string
+	DBHost          string
+	DBPort          string
+	DBUser          string
+	DBPassword      string
+	DBName          string
+	JWTSecret       string
+	ServerPort      string
+	SecureCookie    bool
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+
+	// TrustedProxies lists the addresses or CIDR ranges of reverse proxies
+	// whose X-Forwarded-For header is believed. With none, the client IP is
+	// always the connection's peer address.
+	TrustedProxies []string
+
+	// JWTKeys lists signing keys as kid:alg:path (alg is HS256, RS256, ES256
+	// or EdDSA); JWTActiveKey names the one that signs new tokens.
+	JWTKeys      []string
+	JWTActiveKey string
+	// Keyring holds the loaded keys, including JWTSecret as kid "default".
+	Keyring *utils.Keyring
+
+	// BaseURL is the public address used to build links in outgoing email.
+	BaseURL string
+
+	MailDriver       string
+	MailFrom         string
+	MailOutboxDir    string
+	SMTPHost         string
+	SMTPPort         string
+	SMTPUser         string
+	SMTPPassword     string
+	PasswordResetTTL time.Duration
+
+	EmailVerificationTTL time.Duration
+	// RequireEmailVerification blocks posting and commenting until the
+	// user has confirmed their email address.
+	RequireEmailVerification bool
+
+	// MFARequiredRoles lists roles that must sign in with two-factor
+	// authentication before they can use the site.
+	MFARequiredRoles []string
+
+	// OpenID Connect single sign-on; disabled when OIDCIssuer is empty.
+	OIDCIssuer       string
+	OIDCClientID     string
+	OIDCClientSecret string
+	OIDCRedirectURL  string
+	OIDCScopes       []string
+	OIDCProviderName string
+	// OIDCAutoRegister creates a local account on first sign-in when no
+	// account with the provider's verified email exists.
+	OIDCAutoRegister bool
+
+	// ThrottleStore keeps failed login counts: "memory" for a single
+	// instance, "database" when running several replicas.
+	ThrottleStore string
+	// Failures allowed per account and per client IP before lockouts start.
+	LoginMaxAccountAttempts int
+	LoginMaxIPAttempts      int
+	// The first lockout lasts LoginLockoutBase and doubles with each further
+	// failure, up to LoginLockoutMax. Counts reset after LoginAttemptWindow
+	// without failures.
+	LoginLockoutBase   time.Duration
+	LoginLockoutMax    time.Duration
+	LoginAttemptWindow time.Duration
+
+	// MediaDir stores user uploads, served under /media.
+	MediaDir       string
+	AvatarMaxBytes int
+	// AvatarSize is the width and height, in pixels, avatars are scaled to.
+	AvatarSize int
+	// MaxBodyBytes caps every request body, uploads included, so it must
+	// leave room for an avatar of AvatarMaxBytes.
+	MaxBodyBytes int
+
+	// PasswordMinLength and PasswordRequire (character classes: lower,
+	// upper, digit, symbol) shape new passwords; PasswordForbidIdentity
+	// rejects ones containing the username or email.
+	PasswordMinLength      int
+	PasswordRequire        []string
+	PasswordForbidIdentity bool
+	// BreachedPasswordsDir holds SHA-1 hash-prefix files of breached
+	// passwords; empty disables the check. Hashes seen fewer than
+	// BreachedPasswordsMinCount times are allowed.
+	BreachedPasswordsDir      string
+	BreachedPasswordsMinCount int
+	PasswordPolicy            *utils.PasswordPolicy
+	// PasswordHash is the algorithm new password hashes use (argon2id or
+	// bcrypt). Hashes made with another algorithm or other parameters are
+	// upgraded the next time their owner signs in.
+	PasswordHash      string
+	BcryptCost        int
+	Argon2Memory      int // KiB
+	Argon2Iterations  int
+	Argon2Parallelism int
+	PasswordHasher    utils.PasswordHasher
+
+	// RegistrationMode is RegistrationOpen, RegistrationInvite (sign-up
+	// needs an invite code) or RegistrationClosed.
+	RegistrationMode string
+
+	// PostSchedulerInterval is how often scheduled posts are checked and
+	// published once their time has come.
+	PostSchedulerInterval time.Duration
 }
 
+// Registration modes.
+const (
+	RegistrationOpen   = "open"
+	RegistrationInvite = "invite"
+	RegistrationClosed = "closed"
+)
+
 const defaultJWTSecret = "secret-key-change-in-production"
 
 // loadDotEnv reads a .env file and sets environment variables.
@@ -61,21 +170,106 @@ func LoadConfig() *Config {
 	loadDotEnv(".env")
 
 	jwtSecret := getEnv("JWT_SECRET", defaultJWTSecret)
-	if jwtSecret == defaultJWTSecret {
-		log.Println("WARNING: Using default JWT secret. Set JWT_SECRET environment variable for production.")
+
+	cfg := &Config{
+		DBDriver:        getEnv("DB_DRIVER", "mysql"),
+		DBHost:          getEnv("DB_HOST", "localhost"),
+		DBPort:          getEnv("DB_PORT", "3306"),
+		DBUser:          getEnv("DB_USER", "root"),
+		DBPassword:      getEnv("DB_PASSWORD", ""),
+		DBName:          getEnv("DB_NAME", "simple_blog"),
+		JWTSecret:       jwtSecret,
+		ServerPort:      getEnv("SERVER_PORT", "8080"),
+		SecureCookie:    getEnv("SECURE_COOKIE", "true") != "false",
+		AccessTokenTTL:  getEnvDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL: getEnvDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+		TrustedProxies:  getEnvList("TRUSTED_PROXIES"),
+		JWTKeys:         getEnvList("JWT_KEYS"),
+		JWTActiveKey:    getEnv("JWT_ACTIVE_KEY", ""),
+
+		BaseURL: strings.TrimRight(getEnv("BASE_URL", "http://localhost:8080"), "/"),
+
+		MailDriver:       getEnv("MAIL_DRIVER", "file"),
+		MailFrom:         getEnv("MAIL_FROM", "Simple Blog <no-reply@localhost>"),
+		MailOutboxDir:    getEnv("MAIL_OUTBOX_DIR", "storage/outbox"),
+		SMTPHost:         getEnv("SMTP_HOST", "localhost"),
+		SMTPPort:         getEnv("SMTP_PORT", "587"),
+		SMTPUser:         getEnv("SMTP_USER", ""),
+		SMTPPassword:     getEnv("SMTP_PASSWORD", ""),
+		PasswordResetTTL: getEnvDuration("PASSWORD_RESET_TTL", time.Hour),
+
+		EmailVerificationTTL:     getEnvDuration("EMAIL_VERIFICATION_TTL", 48*time.Hour),
+		RequireEmailVerification: getEnv("REQUIRE_EMAIL_VERIFICATION", "false") == "true",
+
+		MFARequiredRoles: getEnvList("MFA_REQUIRED_ROLES"),
+
+		OIDCIssuer:       getEnv("OIDC_ISSUER", ""),
+		OIDCClientID:     getEnv("OIDC_CLIENT_ID", ""),
+		OIDCClientSecret: getEnv("OIDC_CLIENT_SECRET", ""),
+		OIDCRedirectURL:  getEnv("OIDC_REDIRECT_URL", ""),
+		OIDCScopes:       getEnvList("OIDC_SCOPES"),
+		OIDCProviderName: getEnv("OIDC_PROVIDER_NAME", "Single Sign-On"),
+		OIDCAutoRegister: getEnv("OIDC_AUTO_REGISTER", "true") != "false",
+
+		ThrottleStore:           getEnv("THROTTLE_STORE", "memory"),
+		LoginMaxAccountAttempts: getEnvInt("LOGIN_MAX_ACCOUNT_ATTEMPTS", 5),
+		LoginMaxIPAttempts:      getEnvInt("LOGIN_MAX_IP_ATTEMPTS", 20),
+		LoginLockoutBase:        getEnvDuration("LOGIN_LOCKOUT_BASE", 30*time.Second),
+		LoginLockoutMax:         getEnvDuration("LOGIN_LOCKOUT_MAX", 15*time.Minute),
+		LoginAttemptWindow:      getEnvDuration("LOGIN_ATTEMPT_WINDOW", time.Hour),
+
+		MediaDir:       getEnv("MEDIA_DIR", "storage/media"),
+		AvatarMaxBytes: getEnvInt("AVATAR_MAX_BYTES", 2<<20),
+		AvatarSize:     getEnvInt("AVATAR_SIZE", 256),
+		MaxBodyBytes:   getEnvInt("MAX_BODY_BYTES", 4<<20),
+
+		PasswordMinLength:         getEnvInt("PASSWORD_MIN_LENGTH", 8),
+		PasswordRequire:           getEnvList("PASSWORD_REQUIRE"),
+		PasswordForbidIdentity:    getEnv("PASSWORD_FORBID_IDENTITY", "true") != "false",
+		BreachedPasswordsDir:      getEnv("BREACHED_PASSWORDS_DIR", ""),
+		BreachedPasswordsMinCount: getEnvInt("BREACHED_PASSWORDS_MIN_COUNT", 1),
+		PasswordHash:              getEnv("PASSWORD_HASH", utils.HashArgon2id),
+		BcryptCost:                getEnvInt("BCRYPT_COST", 12),
+		Argon2Memory:              getEnvInt("ARGON2_MEMORY", 64*1024),
+		Argon2Iterations:          getEnvInt("ARGON2_ITERATIONS", 3),
+		Argon2Parallelism:         getEnvInt("ARGON2_PARALLELISM", 2),
+
+		RegistrationMode: getEnv("REGISTRATION_MODE", RegistrationOpen),
+
+		PostSchedulerInterval: getEnvDuration("POST_SCHEDULER_INTERVAL", time.Minute),
+	}
+	if cfg.OIDCRedirectURL == "" {
+		cfg.OIDCRedirectURL = cfg.BaseURL + "/auth/oidc/callback"
+	}
+
+	switch cfg.RegistrationMode {
+	case RegistrationOpen, RegistrationInvite, RegistrationClosed:
+	default:
+		log.Fatalf("Invalid REGISTRATION_MODE %q: use open, invite or closed", cfg.RegistrationMode)
 	}
 
-	return &Config{
-		DBDriver:     getEnv("DB_DRIVER", "mysql"),
-		DBHost:       getEnv("DB_HOST", "localhost"),
-		DBPort:       getEnv("DB_PORT", "3306"),
-		DBUser:       getEnv("DB_USER", "root"),
-		DBPassword:   getEnv("DB_PASSWORD", ""),
-		DBName:       getEnv("DB_NAME", "simple_blog"),
-		JWTSecret:    jwtSecret,
-		ServerPort:   getEnv("SERVER_PORT", "8080"),
-		SecureCookie: getEnv("SECURE_COOKIE", "true") != "false",
+	if cfg.MaxBodyBytes <= cfg.AvatarMaxBytes {
+		log.Fatalf("MAX_BODY_BYTES (%d) must be larger than AVATAR_MAX_BYTES (%d)", cfg.MaxBodyBytes, cfg.AvatarMaxBytes)
+	}
+
+	keyring, err := loadKeyring(cfg)
+	if err != nil {
+		log.Fatalf("Failed to load JWT keys: %v", err)
+	}
+	cfg.Keyring = keyring
+
+	hasher, err := loadPasswordHasher(cfg)
+	if err != nil {
+		log.Fatalf("Invalid password hashing settings: %v", err)
+	}
+	cfg.PasswordHasher = hasher
+
+	policy, err := loadPasswordPolicy(cfg, hasher)
+	if err != nil {
+		log.Fatalf("Invalid password policy: %v", err)
 	}
+	cfg.PasswordPolicy = policy
+	return cfg
 }
 
 func getEnv(key, defaultVal string) string {
@@ -84,3 +278,45 @@ func getEnv(key, defaultVal string) string {
 	}
 	return defaultVal
 }
+
+// getEnvDuration parses a Go duration string (e.g. "15m", "720h") from the
+// environment, falling back to defaultVal when unset or invalid.
+func getEnvDuration(key string, defaultVal time.Duration) time.Duration {
+	val := os.Getenv(key)
+	if val == "" {
+		return defaultVal
+	}
+	d, err := time.ParseDuration(val)
+	if err != nil || d <= 0 {
+		log.Printf("WARNING: Invalid duration %q for %s, using %s.", val, key, defaultVal)
+		return defaultVal
+	}
+	return d
+}
+
+// getEnvInt parses a non-negative integer from the environment, falling back
+// to defaultVal when unset or invalid.
+func getEnvInt(key string, defaultVal int) int {
+	val := os.Getenv(key)
+	if val == "" {
+		return defaultVal
+	}
+	n, err := strconv.Atoi(val)
+	if err != nil || n < 0 {
+		log.Printf("WARNING: Invalid number %q for %s, using %d.", val, key, defaultVal)
+		return defaultVal
+	}
+	return n
+}
+
+// getEnvList splits a comma-separated environment variable, dropping empty
+// entries.
+func getEnvList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
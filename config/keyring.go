@@ -0,0 +1,76 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/Jason-cqtan/simple-blog/utils"
+)
+
+// legacyKeyID names the JWT_SECRET key. It also verifies tokens issued
+// before key IDs were introduced, which carry no kid header.
+const legacyKeyID = "default"
+
+// loadKeyring builds the JWT keyring. Without JWT_KEYS, JWT_SECRET signs
+// everything with HS256 as before. With JWT_KEYS, the listed keys are
+// loaded and JWT_SECRET is kept only for verification, and only when it
+// was set explicitly, so tokens signed with it stay valid until they expire.
+func loadKeyring(cfg *Config) (*utils.Keyring, error) {
+	ring := utils.NewKeyring()
+
+	if len(cfg.JWTKeys) == 0 {
+		if cfg.JWTSecret == defaultJWTSecret {
+			log.Println("WARNING: Using default JWT secret. Set JWT_SECRET or JWT_KEYS for production.")
+		}
+		if err := ring.AddHMAC(legacyKeyID, []byte(cfg.JWTSecret)); err != nil {
+			return nil, err
+		}
+		if err := ring.SetActive(legacyKeyID); err != nil {
+			return nil, err
+		}
+		return ring, ring.SetLegacy(legacyKeyID)
+	}
+
+	for _, entry := range cfg.JWTKeys {
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid JWT_KEYS entry %q, expected kid:alg:path", entry)
+		}
+		kid, alg, path := parts[0], parts[1], parts[2]
+		if kid == legacyKeyID {
+			return nil, fmt.Errorf("key ID %q is reserved for JWT_SECRET", legacyKeyID)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if alg == "HS256" {
+			err = ring.AddHMAC(kid, []byte(strings.TrimSpace(string(data))))
+		} else {
+			err = ring.AddPEM(kid, alg, data)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", kid, err)
+		}
+	}
+
+	active := cfg.JWTActiveKey
+	if active == "" {
+		active, _, _ = strings.Cut(cfg.JWTKeys[0], ":")
+	}
+	if err := ring.SetActive(active); err != nil {
+		return nil, err
+	}
+
+	if cfg.JWTSecret != defaultJWTSecret {
+		if err := ring.AddHMAC(legacyKeyID, []byte(cfg.JWTSecret)); err != nil {
+			return nil, err
+		}
+		if err := ring.SetLegacy(legacyKeyID); err != nil {
+			return nil, err
+		}
+	}
+	return ring, nil
+}
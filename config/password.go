@@ -0,0 +1,37 @@
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Jason-cqtan/simple-blog/utils"
+)
+
+// loadPasswordPolicy builds the policy new passwords are checked against.
+// Passwords may be as long as hasher takes in full.
+func loadPasswordPolicy(cfg *Config, hasher utils.PasswordHasher) (*utils.PasswordPolicy, error) {
+	var breached *utils.BreachedPasswords
+	if cfg.BreachedPasswordsDir != "" {
+		info, err := os.Stat(cfg.BreachedPasswordsDir)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("BREACHED_PASSWORDS_DIR %s is not a directory", cfg.BreachedPasswordsDir)
+		}
+		breached = &utils.BreachedPasswords{
+			Dir:      cfg.BreachedPasswordsDir,
+			MinCount: cfg.BreachedPasswordsMinCount,
+		}
+	}
+	return utils.NewPasswordPolicy(cfg.PasswordMinLength, hasher.MaxPasswordBytes(), cfg.PasswordRequire, cfg.PasswordForbidIdentity, breached)
+}
+
+// loadPasswordHasher builds the hasher new passwords are stored with.
+func loadPasswordHasher(cfg *Config) (utils.PasswordHasher, error) {
+	if cfg.Argon2Memory < 0 || cfg.Argon2Iterations < 0 || cfg.Argon2Parallelism < 0 || cfg.Argon2Parallelism > 255 {
+		return nil, fmt.Errorf("ARGON2_MEMORY, ARGON2_ITERATIONS and ARGON2_PARALLELISM must be positive, and parallelism at most 255")
+	}
+	return utils.NewPasswordHasher(cfg.PasswordHash, cfg.BcryptCost,
+		uint32(cfg.Argon2Memory), uint32(cfg.Argon2Iterations), uint8(cfg.Argon2Parallelism))
+}
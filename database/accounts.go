@@ -0,0 +1,103 @@
+package database
+
+import (
+	"github.com/Jason-cqtan/simple-blog/models"
+	"gorm.io/gorm"
+)
+
+// VerifyExistingEmails marks every account without a verification date as
+// verified when it was created. InitDB runs it once, when it adds the
+// email_verified_at column, so that accounts from before email verification
+// are not locked out when REQUIRE_EMAIL_VERIFICATION is on.
+func VerifyExistingEmails(db *gorm.DB) error {
+	return db.Model(&models.User{}).Where("email_verified_at IS NULL").
+		UpdateColumn("email_verified_at", gorm.Expr("created_at")).Error
+}
+
+// DeleteUser removes a user together with their sessions, tokens, invites,
+// recovery codes and linked identities. With anonymize their posts and
+// comments are reassigned to the shared "deleted user" placeholder; otherwise
+// they are deleted, along with other users' comments and the revisions and
+// tag links of those posts. Revisions they saved elsewhere no longer name an
+// editor.
+func DeleteUser(db *gorm.DB, userID uint, anonymize bool) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if anonymize {
+			placeholder, err := deletedUser(tx)
+			if err != nil {
+				return err
+			}
+			if err := tx.Model(&models.Post{}).Where("author_id = ?", userID).
+				Update("author_id", placeholder.ID).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.Comment{}).Where("author_id = ?", userID).
+				Update("author_id", placeholder.ID).Error; err != nil {
+				return err
+			}
+		} else {
+			ownPosts := tx.Model(&models.Post{}).Select("id").Where("author_id = ?", userID)
+			if err := tx.Where("post_id IN (?) OR author_id = ?", ownPosts, userID).
+				Delete(&models.Comment{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("post_id IN (?)", ownPosts).Delete(&models.PostRevision{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("post_id IN (?)", ownPosts).Delete(&models.PostSlug{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec("DELETE FROM post_tags WHERE post_id IN (?)", ownPosts).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("author_id = ?", userID).Delete(&models.Post{}).Error; err != nil {
+				return err
+			}
+		}
+
+		for _, model := range []interface{}{
+			&models.Session{},
+			&models.PasswordReset{},
+			&models.RecoveryCode{},
+			&models.UserIdentity{},
+			&models.PersonalAccessToken{},
+		} {
+			if err := tx.Where("user_id = ?", userID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		// Revisions they saved on other posts are kept without an editor.
+		if err := tx.Model(&models.PostRevision{}).Where("editor_id = ?", userID).
+			Update("editor_id", nil).Error; err != nil {
+			return err
+		}
+
+		// Accounts registered with the user's invites keep working but no
+		// longer point at them.
+		invites := tx.Model(&models.Invite{}).Select("id").Where("created_by_id = ?", userID)
+		if err := tx.Model(&models.User{}).Where("invite_id IN (?)", invites).
+			Update("invite_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("created_by_id = ?", userID).Delete(&models.Invite{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.User{}, userID).Error
+	})
+}
+
+// deletedUser returns the placeholder account, creating it on first use.
+// It has no password, so nobody can sign in as it.
+func deletedUser(tx *gorm.DB) (*models.User, error) {
+	var reader models.Role
+	if err := tx.Where("name = ?", models.RoleReader).First(&reader).Error; err != nil {
+		return nil, err
+	}
+
+	user := &models.User{}
+	err := tx.Where(models.User{Email: models.DeletedUserEmail}).
+		Attrs(models.User{Username: models.DeletedUsername, DisplayName: "Deleted user", RoleID: reader.ID}).
+		FirstOrCreate(user).Error
+	return user, err
+}
@@ -0,0 +1,45 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Jason-cqtan/simple-blog/models"
+	"gorm.io/gorm"
+)
+
+// EnsurePostCategories files posts written before categories had their own
+// table under a top-level category named after the old posts.category
+// value, then empties that column. It runs on every startup and only
+// touches posts whose column still holds a name.
+func EnsurePostCategories(db *gorm.DB) error {
+	if !db.Migrator().HasColumn("posts", "category") {
+		return nil
+	}
+
+	var legacy []struct {
+		ID       uint
+		Category string
+	}
+	if err := db.Table("posts").Select("id, category").Where("category IS NOT NULL AND category <> ?", "").
+		Order("id").Scan(&legacy).Error; err != nil {
+		return err
+	}
+
+	for _, row := range legacy {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			update := map[string]interface{}{"category": ""}
+			category, err := models.FindOrCreateCategory(tx, row.Category)
+			if err == nil {
+				update["category_id"] = category.ID
+			} else if !errors.Is(err, models.ErrCategoryNameEmpty) {
+				return err
+			}
+			return tx.Table("posts").Where("id = ?", row.ID).UpdateColumns(update).Error
+		})
+		if err != nil {
+			return fmt.Errorf("category for post %d: %w", row.ID, err)
+		}
+	}
+	return nil
+}
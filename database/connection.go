@@ -29,9 +29,61 @@ func InitDB(cfg *config.Config) (*gorm.DB, error) {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	if err := db.AutoMigrate(&models.User{}, &models.Post{}, &models.Comment{}); err != nil {
+	// Accounts created before email verification existed are treated as
+	// verified; see VerifyExistingEmails.
+	verifyExisting := db.Migrator().HasTable(&models.User{}) &&
+		!db.Migrator().HasColumn(&models.User{}, "EmailVerifiedAt")
+
+	if err := db.AutoMigrate(
+		&models.Role{},
+		&models.Permission{},
+		&models.User{},
+		&models.Post{},
+		&models.PostSlug{},
+		&models.PostRevision{},
+		&models.Tag{},
+		&models.Category{},
+		&models.Comment{},
+		&models.Session{},
+		&models.PasswordReset{},
+		&models.RecoveryCode{},
+		&models.UserIdentity{},
+		&models.PersonalAccessToken{},
+		&models.LoginAttempt{},
+		&models.Invite{},
+	); err != nil {
 		return nil, fmt.Errorf("failed to auto-migrate: %w", err)
 	}
 
+	if verifyExisting {
+		if err := VerifyExistingEmails(db); err != nil {
+			return nil, fmt.Errorf("failed to verify existing accounts: %w", err)
+		}
+	}
+
+	if err := EnsureRoles(db); err != nil {
+		return nil, fmt.Errorf("failed to set up roles: %w", err)
+	}
+
+	if err := EnsurePostStatuses(db); err != nil {
+		return nil, fmt.Errorf("failed to set post statuses: %w", err)
+	}
+
+	if err := EnsurePostSlugs(db); err != nil {
+		return nil, fmt.Errorf("failed to assign post slugs: %w", err)
+	}
+
+	if err := EnsurePostCategories(db); err != nil {
+		return nil, fmt.Errorf("failed to migrate post categories: %w", err)
+	}
+
+	if err := EnsurePostTags(db); err != nil {
+		return nil, fmt.Errorf("failed to migrate post tags: %w", err)
+	}
+
+	if err := EnsurePostRevisions(db); err != nil {
+		return nil, fmt.Errorf("failed to record post revisions: %w", err)
+	}
+
 	return db, nil
 }
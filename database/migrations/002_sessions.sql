@@ -0,0 +1,17 @@
+-- Migration: 002_sessions
+-- Description: Server-side login sessions backing short-lived access tokens
+--              and rotating refresh tokens.
+-- Note: GORM AutoMigrate handles table creation automatically.
+--       This file documents the expected schema for reference and manual recovery.
+
+CREATE TABLE IF NOT EXISTS sessions (
+    id                 BIGSERIAL   PRIMARY KEY,
+    user_id            BIGINT      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    refresh_token_hash VARCHAR(64) NOT NULL UNIQUE,
+    expires_at         TIMESTAMPTZ NOT NULL,
+    revoked_at         TIMESTAMPTZ,
+    created_at         TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at         TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions (user_id);
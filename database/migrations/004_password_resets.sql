@@ -0,0 +1,15 @@
+-- Migration: 004_password_resets
+-- Description: Single-use, expiring password reset tokens (stored hashed).
+-- Note: GORM AutoMigrate handles table creation automatically.
+--       This file documents the expected schema for reference and manual recovery.
+
+CREATE TABLE IF NOT EXISTS password_resets (
+    id         BIGSERIAL   PRIMARY KEY,
+    user_id    BIGINT      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at    TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_resets_user_id ON password_resets (user_id);
@@ -0,0 +1,9 @@
+-- Migration: 005_email_verification
+-- Description: Records when a user confirmed their email address. Accounts
+--              that existed before count as verified since their creation.
+-- Note: GORM AutoMigrate handles schema changes automatically.
+--       This file documents the expected schema for reference and manual recovery.
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMPTZ;
+
+UPDATE users SET email_verified_at = created_at WHERE email_verified_at IS NULL;
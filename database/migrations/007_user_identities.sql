@@ -0,0 +1,17 @@
+-- Migration: 007_user_identities
+-- Description: Links local accounts to OpenID Connect provider accounts.
+-- Note: GORM AutoMigrate handles table creation automatically.
+--       This file documents the expected schema for reference and manual recovery.
+
+CREATE TABLE IF NOT EXISTS user_identities (
+    id         BIGSERIAL    PRIMARY KEY,
+    user_id    BIGINT       NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    issuer     VARCHAR(255) NOT NULL,
+    subject    VARCHAR(255) NOT NULL,
+    email      VARCHAR(255),
+    created_at TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_identities_issuer_subject ON user_identities (issuer, subject);
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities (user_id);
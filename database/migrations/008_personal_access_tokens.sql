@@ -0,0 +1,20 @@
+-- Migration: 008_personal_access_tokens
+-- Description: Long-lived, scoped API tokens for scripts and CI (stored hashed).
+-- Note: GORM AutoMigrate handles table creation automatically.
+--       This file documents the expected schema for reference and manual recovery.
+
+CREATE TABLE IF NOT EXISTS personal_access_tokens (
+    id           BIGSERIAL    PRIMARY KEY,
+    user_id      BIGINT       NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name         VARCHAR(100) NOT NULL,
+    token_hash   VARCHAR(64)  NOT NULL UNIQUE,
+    prefix       VARCHAR(16)  NOT NULL,
+    scopes       VARCHAR(255) NOT NULL,
+    mfa          BOOLEAN      NOT NULL DEFAULT FALSE,
+    last_used_at TIMESTAMPTZ,
+    expires_at   TIMESTAMPTZ,
+    revoked_at   TIMESTAMPTZ,
+    created_at   TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_personal_access_tokens_user_id ON personal_access_tokens (user_id);
@@ -0,0 +1,16 @@
+-- Migration: 009_login_attempts
+-- Description: Failed login counters for the database throttle store. The
+--              times are NULL until the first failure and lockout.
+-- Note: GORM AutoMigrate handles table creation automatically.
+--       This file documents the expected schema for reference and manual recovery.
+
+CREATE TABLE IF NOT EXISTS login_attempts (
+    key             VARCHAR(191) PRIMARY KEY,
+    failures        INTEGER      NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMPTZ,
+    locked_until    TIMESTAMPTZ
+);
+
+-- Rows written before the times became nullable hold the zero time instead.
+UPDATE login_attempts SET last_failure_at = NULL WHERE last_failure_at < '1970-01-02';
+UPDATE login_attempts SET locked_until = NULL WHERE locked_until < '1970-01-02';
@@ -0,0 +1,8 @@
+-- Migration: 010_profile_fields
+-- Description: Editable profile fields and uploaded avatar on users.
+-- Note: GORM AutoMigrate handles table creation automatically.
+--       This file documents the expected schema for reference and manual recovery.
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS display_name VARCHAR(100);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS website      VARCHAR(255);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS avatar       VARCHAR(255);
@@ -0,0 +1,11 @@
+-- Migration: 011_user_status
+-- Description: Account suspension/ban status and admin-forced password resets.
+-- Note: GORM AutoMigrate handles table creation automatically.
+--       This file documents the expected schema for reference and manual recovery.
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS status                  VARCHAR(20)  NOT NULL DEFAULT 'active';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS status_reason           VARCHAR(255);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS status_until            TIMESTAMPTZ;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS password_reset_required BOOLEAN      NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS idx_users_status ON users (status);
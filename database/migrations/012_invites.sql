@@ -0,0 +1,24 @@
+-- Migration: 012_invites
+-- Description: Invite codes for invite-only registration, and the invite each
+--              account registered with.
+-- Note: GORM AutoMigrate handles table creation automatically.
+--       This file documents the expected schema for reference and manual recovery.
+
+CREATE TABLE IF NOT EXISTS invites (
+    id            BIGSERIAL    PRIMARY KEY,
+    created_by_id BIGINT       NOT NULL REFERENCES users(id),
+    code_hash     VARCHAR(64)  NOT NULL,
+    prefix        VARCHAR(16)  NOT NULL,
+    note          VARCHAR(255),
+    max_uses      INTEGER      NOT NULL DEFAULT 1,
+    uses          INTEGER      NOT NULL DEFAULT 0,
+    expires_at    TIMESTAMPTZ  NOT NULL,
+    revoked_at    TIMESTAMPTZ,
+    created_at    TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_invites_code_hash ON invites (code_hash);
+CREATE INDEX IF NOT EXISTS idx_invites_created_by_id ON invites (created_by_id);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS invite_id BIGINT;
+CREATE INDEX IF NOT EXISTS idx_users_invite_id ON users (invite_id);
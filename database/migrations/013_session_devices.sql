@@ -0,0 +1,9 @@
+-- Migration: 013_session_devices
+-- Description: Device, IP address and last activity of each login session,
+--              shown on the active sessions page.
+-- Note: GORM AutoMigrate handles table creation automatically.
+--       This file documents the expected schema for reference and manual recovery.
+
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS user_agent   VARCHAR(255);
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS ip           VARCHAR(45);
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS last_seen_at TIMESTAMPTZ;
@@ -0,0 +1,11 @@
+-- Migration: 014_post_content_html
+-- Description: Cached HTML rendering of each post's Markdown content and the
+--              key (content hash + renderer version) it was rendered for.
+-- Note: GORM AutoMigrate handles table creation automatically.
+--       This file documents the expected schema for reference and manual recovery.
+
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS content_html     TEXT;
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS content_html_key VARCHAR(64);
+
+-- MySQL: TEXT holds only 64 KB, less than the HTML of a long post.
+-- ALTER TABLE posts MODIFY content_html LONGTEXT;
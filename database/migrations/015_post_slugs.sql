@@ -0,0 +1,19 @@
+-- Migration: 015_post_slugs
+-- Description: Slug permalinks for posts, and every slug a post has had so
+--              old links can be redirected.
+-- Note: GORM AutoMigrate handles table creation automatically.
+--       This file documents the expected schema for reference and manual recovery.
+--       Existing posts get a slug from their title on the next startup.
+
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS slug VARCHAR(200);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_posts_slug ON posts (slug);
+
+CREATE TABLE IF NOT EXISTS post_slugs (
+    id         BIGSERIAL    PRIMARY KEY,
+    post_id    BIGINT       NOT NULL,
+    slug       VARCHAR(200) NOT NULL,
+    created_at TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_post_slugs_slug ON post_slugs (slug);
+CREATE INDEX IF NOT EXISTS idx_post_slugs_post_id ON post_slugs (post_id);
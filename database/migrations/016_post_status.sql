@@ -0,0 +1,17 @@
+-- Migration: 016_post_status
+-- Description: Draft, scheduled, published and archived post states, the
+--              scheduled publish time and the time a post went live.
+-- Note: GORM AutoMigrate handles table creation automatically.
+--       This file documents the expected schema for reference and manual recovery.
+--       Existing posts get a status and publish date on the next startup.
+
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS status       VARCHAR(20) NOT NULL DEFAULT 'published';
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS publish_at   TIMESTAMPTZ;
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS published_at TIMESTAMPTZ;
+ALTER TABLE posts ALTER COLUMN published SET DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS idx_posts_status ON posts (status);
+CREATE INDEX IF NOT EXISTS idx_posts_publish_at ON posts (publish_at);
+
+UPDATE posts SET status = 'draft' WHERE published = FALSE AND status = 'published';
+UPDATE posts SET published_at = created_at WHERE published = TRUE AND published_at IS NULL;
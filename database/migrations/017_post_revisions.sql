@@ -0,0 +1,22 @@
+-- Migration: 017_post_revisions
+-- Description: A numbered snapshot of a post's title, content, excerpt and
+--              tags each time it is created, edited or restored.
+-- Note: GORM AutoMigrate handles table creation automatically.
+--       This file documents the expected schema for reference and manual recovery.
+--       Existing posts get their current text as revision 1 on the next startup.
+
+CREATE TABLE IF NOT EXISTS post_revisions (
+    id         BIGSERIAL    PRIMARY KEY,
+    post_id    BIGINT       NOT NULL,
+    number     BIGINT       NOT NULL,
+    title      VARCHAR(255) NOT NULL,
+    content    TEXT,
+    excerpt    VARCHAR(500),
+    tags       TEXT,
+    editor_id  BIGINT,
+    note       VARCHAR(255),
+    created_at TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_post_revisions_number ON post_revisions (post_id, number);
+CREATE INDEX IF NOT EXISTS idx_post_revisions_editor_id ON post_revisions (editor_id);
@@ -0,0 +1,30 @@
+-- Migration: 018_tags
+-- Description: Tags as their own table, linked to posts many-to-many and told
+--              apart by name ignoring case, replacing the comma-separated
+--              posts.tags column. Slugs are numbered when names collide.
+-- Note: GORM AutoMigrate handles table creation automatically.
+--       This file documents the expected schema for reference and manual recovery.
+--       On the next startup the values left in posts.tags are split into tags
+--       and the column is emptied; it can be dropped afterwards.
+
+CREATE TABLE IF NOT EXISTS tags (
+    id         BIGSERIAL    PRIMARY KEY,
+    name       VARCHAR(50)  NOT NULL,
+    slug       VARCHAR(100) NOT NULL,
+    created_at TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tags_slug ON tags (slug);
+
+CREATE TABLE IF NOT EXISTS post_tags (
+    post_id BIGINT NOT NULL REFERENCES posts (id),
+    tag_id  BIGINT NOT NULL REFERENCES tags (id),
+    PRIMARY KEY (post_id, tag_id)
+);
+
+-- A post can now have any number of tags, more than a VARCHAR(255) holds.
+-- AutoMigrate widens the column; by hand:
+ALTER TABLE post_revisions ALTER COLUMN tags TYPE TEXT;
+
+-- Once every post's tags have been moved:
+-- ALTER TABLE posts DROP COLUMN tags;
@@ -0,0 +1,27 @@
+-- Migration: 019_categories
+-- Description: Hierarchical categories with a slug, description and display
+--              order, replacing the free-form posts.category column.
+-- Note: GORM AutoMigrate handles table creation automatically.
+--       This file documents the expected schema for reference and manual recovery.
+--       On the next startup each name left in posts.category becomes a
+--       top-level category and the column is emptied; it can be dropped afterwards.
+
+CREATE TABLE IF NOT EXISTS categories (
+    id          BIGSERIAL    PRIMARY KEY,
+    parent_id   BIGINT       REFERENCES categories (id),
+    name        VARCHAR(100) NOT NULL,
+    slug        VARCHAR(100) NOT NULL,
+    description VARCHAR(500),
+    position    BIGINT       NOT NULL DEFAULT 0,
+    created_at  TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    updated_at  TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_categories_slug ON categories (slug);
+CREATE INDEX IF NOT EXISTS idx_categories_parent_id ON categories (parent_id);
+
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS category_id BIGINT REFERENCES categories (id);
+CREATE INDEX IF NOT EXISTS idx_posts_category_id ON posts (category_id);
+
+-- Once every post's category has been moved:
+-- ALTER TABLE posts DROP COLUMN category;
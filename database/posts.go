@@ -0,0 +1,50 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/Jason-cqtan/simple-blog/models"
+	"gorm.io/gorm"
+)
+
+// EnsurePostStatuses fills in the status of posts written before statuses
+// existed: unpublished ones become drafts, and published ones are dated by
+// their creation time. It runs on every startup and only touches posts that
+// still need it.
+func EnsurePostStatuses(db *gorm.DB) error {
+	if err := db.Model(&models.Post{}).
+		Where("published = ? AND status = ?", false, models.PostStatusPublished).
+		UpdateColumn("status", models.PostStatusDraft).Error; err != nil {
+		return err
+	}
+	return db.Model(&models.Post{}).
+		Where("published = ? AND published_at IS NULL", true).
+		UpdateColumn("published_at", gorm.Expr("created_at")).Error
+}
+
+// EnsurePostRevisions records posts written before revisions existed as
+// their first revision, credited to the author and dated by the last edit.
+// It runs on every startup and only touches posts without a revision.
+func EnsurePostRevisions(db *gorm.DB) error {
+	var posts []models.Post
+	if err := db.Preload("Tags", models.OrderTags).Where("id NOT IN (?)", db.Model(&models.PostRevision{}).Select("post_id")).
+		Order("id").Find(&posts).Error; err != nil {
+		return err
+	}
+
+	for _, post := range posts {
+		if err := db.Create(&models.PostRevision{
+			PostID:    post.ID,
+			Number:    1,
+			Title:     post.Title,
+			Content:   post.Content,
+			Excerpt:   post.Excerpt,
+			Tags:      post.TagNames(),
+			EditorID:  &post.AuthorID,
+			CreatedAt: post.UpdatedAt,
+		}).Error; err != nil {
+			return fmt.Errorf("revision for post %d: %w", post.ID, err)
+		}
+	}
+	return nil
+}
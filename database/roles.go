@@ -0,0 +1,91 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/Jason-cqtan/simple-blog/models"
+	"gorm.io/gorm"
+)
+
+var roleDescriptions = map[string]string{
+	models.RoleAdmin:  "Full access, including user management.",
+	models.RoleEditor: "Can moderate any post or comment.",
+	models.RoleAuthor: "Can write and manage their own posts.",
+	models.RoleReader: "Can read and comment.",
+}
+
+// EnsureRoles creates the built-in roles and permissions. It runs on every
+// startup and is idempotent: a role created for the first time receives its
+// default grants, and a permission created for the first time is granted to
+// the default roles that list it. Grants edited in the database afterwards
+// are left alone.
+func EnsureRoles(db *gorm.DB) error {
+	roles := make(map[string]*models.Role)
+	newRoles := make(map[string]bool)
+	for name, desc := range roleDescriptions {
+		role := &models.Role{}
+		result := db.Where(models.Role{Name: name}).Attrs(models.Role{Description: desc}).FirstOrCreate(role)
+		if result.Error != nil {
+			return fmt.Errorf("ensure role %s: %w", name, result.Error)
+		}
+		roles[name] = role
+		newRoles[name] = result.RowsAffected > 0
+	}
+
+	perms := make(map[string]*models.Permission)
+	newPerms := make(map[string]bool)
+	for _, names := range models.DefaultRolePermissions {
+		for _, name := range names {
+			if _, ok := perms[name]; ok {
+				continue
+			}
+			perm := &models.Permission{}
+			result := db.Where(models.Permission{Name: name}).FirstOrCreate(perm)
+			if result.Error != nil {
+				return fmt.Errorf("ensure permission %s: %w", name, result.Error)
+			}
+			perms[name] = perm
+			newPerms[name] = result.RowsAffected > 0
+		}
+	}
+
+	for roleName, names := range models.DefaultRolePermissions {
+		var grant []models.Permission
+		for _, name := range names {
+			if newRoles[roleName] || newPerms[name] {
+				grant = append(grant, *perms[name])
+			}
+		}
+		if len(grant) == 0 {
+			continue
+		}
+		if err := db.Model(roles[roleName]).Association("Permissions").Append(grant); err != nil {
+			return fmt.Errorf("grant permissions to %s: %w", roleName, err)
+		}
+	}
+
+	// Accounts created before roles existed become authors, which matches
+	// what they were allowed to do until now.
+	if err := db.Model(&models.User{}).
+		Where("role_id IS NULL OR role_id = 0").
+		Update("role_id", roles[models.RoleAuthor].ID).Error; err != nil {
+		return fmt.Errorf("backfill user roles: %w", err)
+	}
+	return nil
+}
+
+// AssignRole gives the user with the given username the named role.
+func AssignRole(db *gorm.DB, username, roleName string) error {
+	var role models.Role
+	if err := db.Where("name = ?", roleName).First(&role).Error; err != nil {
+		return fmt.Errorf("find role %s: %w", roleName, err)
+	}
+	result := db.Model(&models.User{}).Where("username = ?", username).Update("role_id", role.ID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("user %s not found", username)
+	}
+	return nil
+}
@@ -6,14 +6,16 @@ import (
 	"time"
 
 	"github.com/Jason-cqtan/simple-blog/models"
+	"github.com/Jason-cqtan/simple-blog/utils"
 	"gorm.io/gorm"
 )
 
 // Seed populates the database with default admin user, sample posts, and
 // sample comments.  All operations are idempotent – running Seed multiple
-// times will not create duplicate records.
-func Seed(db *gorm.DB) error {
-	admin, err := seedAdminUser(db)
+// times will not create duplicate records. The admin password is stored with
+// hasher.
+func Seed(db *gorm.DB, hasher utils.PasswordHasher) error {
+	admin, err := seedAdminUser(db, hasher)
 	if err != nil {
 		return fmt.Errorf("seed admin user: %w", err)
 	}
@@ -31,24 +33,41 @@ func Seed(db *gorm.DB) error {
 	return nil
 }
 
-// seedAdminUser creates the default admin account if it does not exist yet.
-func seedAdminUser(db *gorm.DB) (*models.User, error) {
+// seedAdminUser creates the default admin account if it does not exist yet and
+// makes sure it holds the admin role.
+func seedAdminUser(db *gorm.DB, hasher utils.PasswordHasher) (*models.User, error) {
+	var role models.Role
+	if err := db.Where("name = ?", models.RoleAdmin).First(&role).Error; err != nil {
+		return nil, fmt.Errorf("find admin role: %w", err)
+	}
+
 	admin := &models.User{}
 	result := db.Where("username = ?", "admin").First(admin)
 	if result.Error == nil {
-		log.Println("Admin user already exists, skipping.")
+		// Accounts seeded before roles existed were backfilled as authors.
+		if admin.RoleID != role.ID {
+			if err := db.Model(admin).Update("role_id", role.ID).Error; err != nil {
+				return nil, fmt.Errorf("promote admin user: %w", err)
+			}
+			log.Println("Granted admin role to existing admin user.")
+		} else {
+			log.Println("Admin user already exists, skipping.")
+		}
 		return admin, nil
 	}
 	if result.Error != gorm.ErrRecordNotFound {
 		return nil, result.Error
 	}
 
+	verifiedAt := time.Now()
 	admin = &models.User{
-		Username: "admin",
-		Email:    "admin@example.com",
-		Bio:      "Default administrator account.",
+		Username:        "admin",
+		Email:           "admin@example.com",
+		EmailVerifiedAt: &verifiedAt,
+		Bio:             "Default administrator account.",
+		RoleID:          role.ID,
 	}
-	if err := admin.HashPassword("Admin@123456"); err != nil {
+	if err := admin.HashPassword(hasher, "Admin@123456"); err != nil {
 		return nil, fmt.Errorf("hash admin password: %w", err)
 	}
 	if err := db.Create(admin).Error; err != nil {
@@ -75,8 +94,6 @@ func seedPosts(db *gorm.DB, adminID uint) ([]models.Post, error) {
 			Content:   "This is the first post on Simple Blog. Feel free to explore the features: create posts, leave comments, and manage your profile.",
 			Excerpt:   "Welcome to Simple Blog – your new Go-powered blogging platform.",
 			AuthorID:  adminID,
-			Category:  "General",
-			Tags:      "welcome,intro",
 			Published: true,
 			CreatedAt: time.Now(),
 			UpdatedAt: time.Now(),
@@ -86,8 +103,6 @@ func seedPosts(db *gorm.DB, adminID uint) ([]models.Post, error) {
 			Content:   "Go is a statically typed, compiled language designed for simplicity and performance. The Gin framework makes it easy to build fast HTTP servers with clean routing and middleware support.",
 			Excerpt:   "A brief introduction to building web applications with Go and the Gin framework.",
 			AuthorID:  adminID,
-			Category:  "Technology",
-			Tags:      "go,gin,web",
 			Published: true,
 			CreatedAt: time.Now(),
 			UpdatedAt: time.Now(),
@@ -97,14 +112,27 @@ func seedPosts(db *gorm.DB, adminID uint) ([]models.Post, error) {
 			Content:   "GORM is a full-featured ORM library for Go. Combined with PostgreSQL it provides powerful tools for schema migration, associations, and querying.",
 			Excerpt:   "Learn how to use GORM with PostgreSQL in a Go web application.",
 			AuthorID:  adminID,
-			Category:  "Technology",
-			Tags:      "go,gorm,postgresql",
 			Published: true,
 			CreatedAt: time.Now(),
 			UpdatedAt: time.Now(),
 		},
 	}
 
+	sampleCategories := []string{"General", "Technology", "Technology"}
+	sampleTags := []string{"welcome, intro", "go, gin, web", "go, gorm, postgresql"}
+	for i := range samples {
+		category, err := models.FindOrCreateCategory(db, sampleCategories[i])
+		if err != nil {
+			return nil, err
+		}
+		samples[i].CategoryID = &category.ID
+		tags, err := models.FindOrCreateTags(db, sampleTags[i])
+		if err != nil {
+			return nil, err
+		}
+		samples[i].Tags = tags
+	}
+
 	if err := db.Create(&samples).Error; err != nil {
 		return nil, err
 	}
@@ -0,0 +1,37 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/Jason-cqtan/simple-blog/models"
+	"gorm.io/gorm"
+)
+
+// EnsurePostSlugs gives posts created before slugs existed one made from
+// their title. It runs on every startup and only touches posts without a
+// slug.
+func EnsurePostSlugs(db *gorm.DB) error {
+	var posts []models.Post
+	if err := db.Where("slug IS NULL OR slug = ?", "").Order("id").Find(&posts).Error; err != nil {
+		return err
+	}
+
+	for i := range posts {
+		post := &posts[i]
+		err := db.Transaction(func(tx *gorm.DB) error {
+			slug, err := models.UniquePostSlug(tx, post.ID, post.Title)
+			if err != nil {
+				return err
+			}
+			post.Slug = slug
+			if err := tx.Model(&models.Post{}).Where("id = ?", post.ID).UpdateColumn("slug", slug).Error; err != nil {
+				return err
+			}
+			return post.AfterSave(tx)
+		})
+		if err != nil {
+			return fmt.Errorf("slug for post %d: %w", post.ID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/Jason-cqtan/simple-blog/models"
+	"gorm.io/gorm"
+)
+
+// EnsurePostTags moves the comma-separated tags of posts written before the
+// tags table existed into it, then empties the old posts.tags column. It runs
+// on every startup and only touches posts whose column still holds tags.
+func EnsurePostTags(db *gorm.DB) error {
+	if !db.Migrator().HasColumn("posts", "tags") {
+		return nil
+	}
+
+	var legacy []struct {
+		ID   uint
+		Tags string
+	}
+	if err := db.Table("posts").Select("id, tags").Where("tags IS NOT NULL AND tags <> ?", "").
+		Order("id").Scan(&legacy).Error; err != nil {
+		return err
+	}
+
+	for _, row := range legacy {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			tags, err := models.FindOrCreateTags(tx, row.Tags)
+			if err != nil {
+				return err
+			}
+			if len(tags) > 0 {
+				if err := tx.Model(&models.Post{ID: row.ID}).Association("Tags").Append(tags); err != nil {
+					return err
+				}
+			}
+			return tx.Table("posts").Where("id = ?", row.ID).UpdateColumn("tags", "").Error
+		})
+		if err != nil {
+			return fmt.Errorf("tags for post %d: %w", row.ID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+// Package diff compares two texts line by line or word by word, for showing
+// what changed between post revisions.
+package diff
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// Kinds of Op.
+const (
+	Equal  = "equal"
+	Delete = "delete"
+	Insert = "insert"
+)
+
+// Op is a run of text that both sides share (Equal), that only the old side
+// has (Delete) or that only the new side has (Insert). Concatenating the
+// Equal and Delete ops gives the old text; Equal and Insert the new one.
+type Op struct {
+	Kind string
+	Text string
+}
+
+// tokenPattern splits text into words and the whitespace between them, so
+// word diffs keep line breaks and indentation.
+var tokenPattern = regexp.MustCompile(`\s+|\S+`)
+
+// Lines diffs old and new a line at a time.
+func Lines(old, new string) []Op {
+	return compare(splitLines(old), splitLines(new))
+}
+
+// Words diffs old and new a word at a time.
+func Words(old, new string) []Op {
+	return compare(tokenPattern.FindAllString(old, -1), tokenPattern.FindAllString(new, -1))
+}
+
+// Changed reports whether ops has any insertions or deletions.
+func Changed(ops []Op) bool {
+	for _, op := range ops {
+		if op.Kind != Equal {
+			return true
+		}
+	}
+	return false
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.SplitAfter(s, "\n")
+}
+
+func compare(a, b []string) []Op {
+	// Without autojunk, common lines and words such as blank lines or "the"
+	// still anchor matches in long texts.
+	m := difflib.NewMatcherWithJunk(a, b, false, nil)
+
+	var ops []Op
+	add := func(kind string, tokens []string) {
+		if len(tokens) == 0 {
+			return
+		}
+		text := strings.Join(tokens, "")
+		if n := len(ops); n > 0 && ops[n-1].Kind == kind {
+			ops[n-1].Text += text
+			return
+		}
+		ops = append(ops, Op{Kind: kind, Text: text})
+	}
+	for _, c := range m.GetOpCodes() {
+		switch c.Tag {
+		case 'e':
+			add(Equal, a[c.I1:c.I2])
+		case 'd':
+			add(Delete, a[c.I1:c.I2])
+		case 'i':
+			add(Insert, b[c.J1:c.J2])
+		case 'r':
+			add(Delete, a[c.I1:c.I2])
+			add(Insert, b[c.J1:c.J2])
+		}
+	}
+	return ops
+}
@@ -0,0 +1,90 @@
+package diff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     []Op
+	}{
+		{name: "both empty", old: "", new: "", want: nil},
+		{name: "from empty", old: "", new: "a\nb\n", want: []Op{{Insert, "a\nb\n"}}},
+		{name: "to empty", old: "a\n", new: "", want: []Op{{Delete, "a\n"}}},
+		{name: "unchanged", old: "a\nb\n", new: "a\nb\n", want: []Op{{Equal, "a\nb\n"}}},
+		{name: "insert in the middle", old: "a\nc\n", new: "a\nb\nc\n", want: []Op{{Equal, "a\n"}, {Insert, "b\n"}, {Equal, "c\n"}}},
+		{name: "replace", old: "a\nb\nc\n", new: "a\nB\nc\n", want: []Op{{Equal, "a\n"}, {Delete, "b\n"}, {Insert, "B\n"}, {Equal, "c\n"}}},
+		{name: "missing final newline", old: "a\nb", new: "a\nb\n", want: []Op{{Equal, "a\n"}, {Delete, "b"}, {Insert, "b\n"}}},
+		{name: "blank lines anchor", old: "x\n\ny\n", new: "x2\n\ny\n", want: []Op{{Delete, "x\n"}, {Insert, "x2\n"}, {Equal, "\ny\n"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Lines(tt.old, tt.new)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Lines(%q, %q) = %q, want %q", tt.old, tt.new, got, tt.want)
+			}
+			checkSides(t, got, tt.old, tt.new)
+		})
+	}
+}
+
+func TestWords(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     []Op
+	}{
+		{name: "both empty", old: "", new: "", want: nil},
+		{name: "one word changed", old: "the quick fox", new: "the slow fox", want: []Op{{Equal, "the "}, {Delete, "quick"}, {Insert, "slow"}, {Equal, " fox"}}},
+		{name: "word added", old: "a b", new: "a new b", want: []Op{{Equal, "a "}, {Insert, "new "}, {Equal, "b"}}},
+		{name: "whitespace change", old: "a b", new: "a\nb", want: []Op{{Equal, "a"}, {Delete, " "}, {Insert, "\n"}, {Equal, "b"}}},
+		{name: "unchanged across lines", old: "one\n  two", new: "one\n  two", want: []Op{{Equal, "one\n  two"}}},
+		{name: "from empty", old: "", new: "hi there", want: []Op{{Insert, "hi there"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Words(tt.old, tt.new)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Words(%q, %q) = %q, want %q", tt.old, tt.new, got, tt.want)
+			}
+			checkSides(t, got, tt.old, tt.new)
+		})
+	}
+}
+
+func TestChanged(t *testing.T) {
+	tests := []struct {
+		ops  []Op
+		want bool
+	}{
+		{nil, false},
+		{[]Op{{Equal, "a"}}, false},
+		{[]Op{{Equal, "a"}, {Insert, "b"}}, true},
+		{[]Op{{Delete, "a"}}, true},
+	}
+	for _, tt := range tests {
+		if got := Changed(tt.ops); got != tt.want {
+			t.Errorf("Changed(%q) = %v, want %v", tt.ops, got, tt.want)
+		}
+	}
+}
+
+// checkSides verifies that the ops rebuild both texts.
+func checkSides(t *testing.T, ops []Op, old, new string) {
+	t.Helper()
+	var gotOld, gotNew string
+	for _, op := range ops {
+		if op.Kind != Insert {
+			gotOld += op.Text
+		}
+		if op.Kind != Delete {
+			gotNew += op.Text
+		}
+	}
+	if gotOld != old || gotNew != new {
+		t.Errorf("ops rebuild %q -> %q, want %q -> %q", gotOld, gotNew, old, new)
+	}
+}
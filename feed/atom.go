@@ -0,0 +1,72 @@
+// Package feed renders Atom (RFC 4287) syndication feeds.
+package feed
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+const atomNamespace = "http://www.w3.org/2005/Atom"
+
+// ContentType is the media type of an Atom feed document.
+const ContentType = "application/atom+xml; charset=utf-8"
+
+type Feed struct {
+	XMLName xml.Name  `xml:"feed"`
+	NS      string    `xml:"xmlns,attr"`
+	ID      string    `xml:"id"`
+	Title   string    `xml:"title"`
+	Updated time.Time `xml:"updated"`
+	Links   []Link    `xml:"link"`
+	Author  *Person   `xml:"author,omitempty"`
+	Entries []Entry   `xml:"entry"`
+}
+
+type Link struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type Person struct {
+	Name string `xml:"name"`
+	URI  string `xml:"uri,omitempty"`
+}
+
+type Entry struct {
+	ID        string    `xml:"id"`
+	Title     string    `xml:"title"`
+	Updated   time.Time `xml:"updated"`
+	Published time.Time `xml:"published"`
+	Links     []Link    `xml:"link"`
+	Author    *Person   `xml:"author,omitempty"`
+	Summary   string    `xml:"summary,omitempty"`
+	Content   *Content  `xml:"content,omitempty"`
+}
+
+type Content struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// Marshal encodes f as an XML document. Updated defaults to the newest
+// entry when unset.
+func Marshal(f *Feed) ([]byte, error) {
+	f.NS = atomNamespace
+	if f.Updated.IsZero() {
+		for _, e := range f.Entries {
+			if e.Updated.After(f.Updated) {
+				f.Updated = e.Updated
+			}
+		}
+	}
+	if f.Updated.IsZero() {
+		f.Updated = time.Now()
+	}
+
+	body, err := xml.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Jason-cqtan/simple-blog/auth"
+	"github.com/Jason-cqtan/simple-blog/config"
+	"github.com/Jason-cqtan/simple-blog/database"
+	"github.com/Jason-cqtan/simple-blog/media"
+	"github.com/Jason-cqtan/simple-blog/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// AccountHandler exports and deletes a user's account.
+type AccountHandler struct {
+	db  *gorm.DB
+	cfg *config.Config
+}
+
+func NewAccountHandler(db *gorm.DB, cfg *config.Config) *AccountHandler {
+	return &AccountHandler{db: db, cfg: cfg}
+}
+
+// accountExport is the personal data archive. Posts and comments are
+// flattened so the archive does not repeat the account for every item.
+type accountExport struct {
+	ExportedAt     time.Time                    `json:"exported_at"`
+	User           models.User                  `json:"user"`
+	Posts          []exportedPost               `json:"posts"`
+	Comments       []exportedComment            `json:"comments"`
+	Identities     []models.UserIdentity        `json:"identities"`
+	AccessTokens   []models.PersonalAccessToken `json:"access_tokens"`
+	Sessions       []models.Session             `json:"sessions"`
+	RecoveryCodes  int                          `json:"recovery_codes_remaining"`
+	AvatarFilename string                       `json:"avatar,omitempty"`
+}
+
+type exportedPost struct {
+	ID          uint       `json:"id"`
+	Title       string     `json:"title"`
+	Content     string     `json:"content"`
+	Excerpt     string     `json:"excerpt"`
+	Category    string     `json:"category"`
+	Tags        string     `json:"tags"`
+	Published   bool       `json:"published"`
+	Slug        string     `json:"slug"`
+	Status      string     `json:"status"`
+	PublishAt   *time.Time `json:"publish_at,omitempty"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+type exportedComment struct {
+	ID        uint      `json:"id"`
+	PostID    uint      `json:"post_id"`
+	PostTitle string    `json:"post_title"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Export downloads everything stored about the signed-in user, as JSON or,
+// with ?format=zip, as a ZIP archive that also contains the avatar image.
+func (h *AccountHandler) Export(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	export, err := h.collect(userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export account"})
+		return
+	}
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export account"})
+		return
+	}
+
+	base := fmt.Sprintf("simple-blog-%s-%s", export.User.Username, export.ExportedAt.Format("20060102"))
+	c.Header("Cache-Control", "no-store")
+
+	if c.Query("format") != "zip" {
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.json"`, base))
+		c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+		return
+	}
+
+	archive, err := h.zip(data, export.User.Avatar)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export account"})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, base))
+	c.Data(http.StatusOK, "application/zip", archive)
+}
+
+func (h *AccountHandler) ShowDeleteForm(c *gin.Context) {
+	h.renderDelete(c, http.StatusOK, gin.H{})
+}
+
+// Delete removes the signed-in user's account after checking their password
+// (and second factor, when enabled). Accounts without a password, such as
+// those created through single sign-on, confirm with their second factor or,
+// without one, by typing their username. The content choice is "delete" or
+// "anonymize".
+func (h *AccountHandler) Delete(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var user models.User
+	if err := h.db.First(&user, userID).Error; err != nil {
+		h.renderDelete(c, http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	content := c.PostForm("content")
+	if content != "delete" && content != "anonymize" {
+		h.renderDelete(c, http.StatusBadRequest, gin.H{"error": "Choose what should happen to your posts and comments"})
+		return
+	}
+	if user.HasPassword() && !user.CheckPassword(c.PostForm("password")) {
+		h.renderDelete(c, http.StatusBadRequest, gin.H{"error": "Password is incorrect"})
+		return
+	}
+	if !user.HasPassword() && !user.TOTPEnabled && c.PostForm("confirm_username") != user.Username {
+		h.renderDelete(c, http.StatusBadRequest, gin.H{"error": "Type your username to confirm"})
+		return
+	}
+	if user.TOTPEnabled && !auth.VerifySecondFactor(h.db, &user, c.PostForm("code")) {
+		h.renderDelete(c, http.StatusBadRequest, gin.H{"error": "Invalid authentication code"})
+		return
+	}
+
+	if err := database.DeleteUser(h.db, user.ID, content == "anonymize"); err != nil {
+		log.Printf("delete user %d: %v", user.ID, err)
+		h.renderDelete(c, http.StatusInternalServerError, gin.H{"error": "Failed to delete account"})
+		return
+	}
+	if err := media.Remove(h.cfg.MediaDir, user.Avatar); err != nil {
+		log.Printf("remove avatar %s: %v", user.Avatar, err)
+	}
+
+	auth.ClearCookies(c, h.cfg)
+	renderHTML(c, http.StatusOK, "users/login.html", gin.H{"title": "Login", "message": "Your account has been deleted."})
+}
+
+func (h *AccountHandler) collect(userID uint) (*accountExport, error) {
+	export := &accountExport{ExportedAt: time.Now().UTC()}
+	if err := h.db.Preload("Role").First(&export.User, userID).Error; err != nil {
+		return nil, err
+	}
+	if export.User.Avatar != "" {
+		export.AvatarFilename = filepath.Base(export.User.Avatar)
+	}
+
+	var posts []models.Post
+	if err := h.db.Preload("Category").Preload("Tags", models.OrderTags).Where("author_id = ?", userID).Order("id").Find(&posts).Error; err != nil {
+		return nil, err
+	}
+	export.Posts = make([]exportedPost, 0, len(posts))
+	for _, p := range posts {
+		export.Posts = append(export.Posts, exportedPost{
+			ID: p.ID, Title: p.Title, Content: p.Content, Excerpt: p.Excerpt,
+			Category: p.CategoryName(), Tags: p.TagNames(), Published: p.Published,
+			Slug: p.Slug, Status: p.Status, PublishAt: p.PublishAt, PublishedAt: p.PublishedAt,
+			CreatedAt: p.CreatedAt, UpdatedAt: p.UpdatedAt,
+		})
+	}
+
+	var comments []models.Comment
+	if err := h.db.Preload("Post").Where("author_id = ?", userID).Order("id").Find(&comments).Error; err != nil {
+		return nil, err
+	}
+	export.Comments = make([]exportedComment, 0, len(comments))
+	for _, cm := range comments {
+		export.Comments = append(export.Comments, exportedComment{
+			ID: cm.ID, PostID: cm.PostID, PostTitle: cm.Post.Title, Content: cm.Content,
+			CreatedAt: cm.CreatedAt, UpdatedAt: cm.UpdatedAt,
+		})
+	}
+
+	for _, dest := range []interface{}{&export.Identities, &export.AccessTokens, &export.Sessions} {
+		if err := h.db.Where("user_id = ?", userID).Order("id").Find(dest).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	var codes int64
+	if err := h.db.Model(&models.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&codes).Error; err != nil {
+		return nil, err
+	}
+	export.RecoveryCodes = int(codes)
+	return export, nil
+}
+
+// zip packs the JSON export and the avatar file, if any, into an archive.
+func (h *AccountHandler) zip(data []byte, avatar string) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	w, err := zw.Create("account.json")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+
+	if avatar != "" && filepath.IsLocal(avatar) {
+		image, err := os.ReadFile(filepath.Join(h.cfg.MediaDir, avatar))
+		if err == nil {
+			w, err := zw.Create("avatar/" + filepath.Base(avatar))
+			if err != nil {
+				return nil, err
+			}
+			if _, err := w.Write(image); err != nil {
+				return nil, err
+			}
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (h *AccountHandler) renderDelete(c *gin.Context, status int, extra gin.H) {
+	userID, _ := c.Get("userID")
+
+	var user models.User
+	h.db.First(&user, userID)
+
+	data := gin.H{
+		"title": "Delete Account",
+		"user":  &user,
+	}
+	for k, v := range extra {
+		data[k] = v
+	}
+	renderHTML(c, status, "users/delete_account.html", data)
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Jason-cqtan/simple-blog/models"
+	"github.com/Jason-cqtan/simple-blog/utils"
+	"github.com/gin-gonic/gin"
+)
+
+func TestAccountDeleteConfirmation(t *testing.T) {
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := utils.TOTPCode(secret, utils.TOTPStep(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		password string // stored password; empty for an SSO-only account
+		totp     bool
+		form     url.Values
+		deleted  bool
+	}{
+		{name: "password", password: "wintergreen7", form: url.Values{"password": {"wintergreen7"}}, deleted: true},
+		{name: "wrong password", password: "wintergreen7", form: url.Values{"password": {"nope"}}},
+		{name: "password account cannot use username", password: "wintergreen7", form: url.Values{"confirm_username": {"dana"}}},
+		{name: "password and code", password: "wintergreen7", totp: true, form: url.Values{"password": {"wintergreen7"}, "code": {code}}, deleted: true},
+		{name: "password without code", password: "wintergreen7", totp: true, form: url.Values{"password": {"wintergreen7"}}},
+		{name: "no password, username", form: url.Values{"confirm_username": {"dana"}}, deleted: true},
+		{name: "no password, wrong username", form: url.Values{"confirm_username": {"Dana"}}},
+		{name: "no password, empty form", form: url.Values{}},
+		{name: "no password, code", totp: true, form: url.Values{"code": {code}}, deleted: true},
+		{name: "no password with 2FA, username is not enough", totp: true, form: url.Values{"confirm_username": {"dana"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t)
+			cfg := newTestConfig(t)
+			user := models.User{Username: "dana", Email: "dana@example.com"}
+			if tt.password != "" {
+				if err := user.HashPassword(cfg.PasswordHasher, tt.password); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.totp {
+				user.TOTPSecret, user.TOTPEnabled = secret, true
+			}
+			if err := db.Create(&user).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			router := newTestRouter(t)
+			h := NewAccountHandler(db, cfg)
+			router.POST("/profile/delete", func(c *gin.Context) { c.Set("userID", user.ID) }, h.Delete)
+
+			tt.form.Set("content", "anonymize")
+			req := httptest.NewRequest(http.MethodPost, "/profile/delete", strings.NewReader(tt.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			var remaining int64
+			db.Model(&models.User{}).Where("id = ?", user.ID).Count(&remaining)
+			if deleted := remaining == 0; deleted != tt.deleted {
+				t.Errorf("deleted = %v, want %v (status %d)", deleted, tt.deleted, rec.Code)
+			}
+			if !tt.deleted && rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400", rec.Code)
+			}
+		})
+	}
+}
+
+func TestAccountDeleteRemovesPostSlugs(t *testing.T) {
+	db := openTestDB(t)
+	cfg := newTestConfig(t)
+	user := models.User{Username: "dana", Email: "dana@example.com"}
+	if err := user.HashPassword(cfg.PasswordHasher, "wintergreen7"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	post := models.Post{Title: "Farewell", Content: "x", AuthorID: user.ID, Published: true}
+	if err := db.Create(&post).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	router := newTestRouter(t)
+	router.POST("/profile/delete", func(c *gin.Context) { c.Set("userID", user.ID) }, NewAccountHandler(db, cfg).Delete)
+	form := url.Values{"password": {"wintergreen7"}, "content": {"delete"}}
+	req := httptest.NewRequest(http.MethodPost, "/profile/delete", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	var posts, slugs int64
+	db.Model(&models.Post{}).Count(&posts)
+	db.Model(&models.PostSlug{}).Count(&slugs)
+	if posts != 0 || slugs != 0 {
+		t.Errorf("posts = %d, slugs = %d after deleting the account with its content; want none (status %d)", posts, slugs, rec.Code)
+	}
+}
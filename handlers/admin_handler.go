@@ -0,0 +1,297 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Jason-cqtan/simple-blog/auth"
+	"github.com/Jason-cqtan/simple-blog/config"
+	"github.com/Jason-cqtan/simple-blog/mailer"
+	"github.com/Jason-cqtan/simple-blog/models"
+	"github.com/Jason-cqtan/simple-blog/throttle"
+	"github.com/Jason-cqtan/simple-blog/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	adminUsersPerPage = 25
+	// adminMaxPerPage caps ?per_page= on the admin lists.
+	adminMaxPerPage = 100
+	// adminActivityLimit caps the posts and comments listed on a user's page.
+	adminActivityLimit = 50
+	// datetimeLocalLayout is the value format of <input type="datetime-local">.
+	datetimeLocalLayout = "2006-01-02T15:04"
+)
+
+type AdminHandler struct {
+	db    *gorm.DB
+	cfg   *config.Config
+	mail  mailer.Mailer
+	guard *throttle.LoginGuard
+}
+
+func NewAdminHandler(db *gorm.DB, cfg *config.Config, mail mailer.Mailer, guard *throttle.LoginGuard) *AdminHandler {
+	return &AdminHandler{db: db, cfg: cfg, mail: mail, guard: guard}
+}
+
+// ListUsers lists accounts, optionally filtered by a search term matched
+// against username, display name and email, and by status.
+func (h *AdminHandler) ListUsers(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	status := c.Query("status")
+
+	query := h.db.Model(&models.User{})
+	if q != "" {
+		like := "%" + strings.ToLower(q) + "%"
+		query = query.Where("LOWER(username) LIKE ? OR LOWER(email) LIKE ? OR LOWER(display_name) LIKE ?", like, like, like)
+	}
+	switch status {
+	case models.UserStatusActive, models.UserStatusSuspended, models.UserStatusBanned:
+		query = query.Where("status = ?", status)
+	default:
+		status = ""
+	}
+
+	var total int64
+	query.Count(&total)
+	pagination := newPagination(c, total, adminUsersPerPage, adminMaxPerPage)
+
+	var users []models.User
+	pagination.Scope(query.Preload("Role").Order("id")).Find(&users)
+
+	renderHTML(c, http.StatusOK, "admin/users.html", gin.H{
+		"title":      "Users",
+		"users":      users,
+		"q":          q,
+		"status":     status,
+		"statuses":   []string{models.UserStatusActive, models.UserStatusSuspended, models.UserStatusBanned},
+		"pagination": pagination,
+		"now":        time.Now(),
+	})
+}
+
+// ShowUser shows an account with its recent posts and comments and the
+// moderation forms.
+func (h *AdminHandler) ShowUser(c *gin.Context) {
+	user, ok := h.findUser(c)
+	if !ok {
+		return
+	}
+	h.renderUser(c, http.StatusOK, user, gin.H{})
+}
+
+// SetStatus suspends, bans or reactivates an account. Suspensions and bans
+// take a reason and an optional expiry, and sign the user out everywhere.
+func (h *AdminHandler) SetStatus(c *gin.Context) {
+	user, ok := h.findUser(c)
+	if !ok || h.refuseSelf(c, user) {
+		return
+	}
+
+	status := c.PostForm("status")
+	reason := strings.TrimSpace(c.PostForm("reason"))
+	var until *time.Time
+
+	switch status {
+	case models.UserStatusActive:
+		reason = ""
+	case models.UserStatusSuspended, models.UserStatusBanned:
+		if reason == "" {
+			h.renderUser(c, http.StatusBadRequest, user, gin.H{"error": "A reason is required"})
+			return
+		}
+		if err := utils.ValidateMaxLength(reason, "reason", 255); err != nil {
+			h.renderUser(c, http.StatusBadRequest, user, gin.H{"error": err.Error()})
+			return
+		}
+		if value := c.PostForm("until"); value != "" {
+			t, err := time.ParseInLocation(datetimeLocalLayout, value, time.Local)
+			if err != nil || !t.After(time.Now()) {
+				h.renderUser(c, http.StatusBadRequest, user, gin.H{"error": "Expiry must be a date in the future"})
+				return
+			}
+			until = &t
+		}
+	default:
+		h.renderUser(c, http.StatusBadRequest, user, gin.H{"error": "Unknown status"})
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{ID: user.ID}).Updates(map[string]interface{}{
+			"status":        status,
+			"status_reason": reason,
+			"status_until":  until,
+		}).Error; err != nil {
+			return err
+		}
+		if status == models.UserStatusActive {
+			return nil
+		}
+		return auth.RevokeUserSessions(tx, user.ID)
+	})
+	if err != nil {
+		h.renderUser(c, http.StatusInternalServerError, user, gin.H{"error": "Failed to update status"})
+		return
+	}
+
+	adminID, _ := c.Get("userID")
+	log.Printf("admin %v set user %d status to %s", adminID, user.ID, status)
+	user.Status, user.StatusReason, user.StatusUntil = status, reason, until
+	h.renderUser(c, http.StatusOK, user, gin.H{"message": "Status changed to " + status + "."})
+}
+
+// RequirePasswordReset signs the user out everywhere and emails a reset
+// link; password sign-in is refused until a new password is set.
+func (h *AdminHandler) RequirePasswordReset(c *gin.Context) {
+	user, ok := h.findUser(c)
+	if !ok || h.refuseSelf(c, user) {
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{ID: user.ID}).Update("password_reset_required", true).Error; err != nil {
+			return err
+		}
+		if err := auth.RevokeUserSessions(tx, user.ID); err != nil {
+			return err
+		}
+		// The account may be compromised, so its API access ends too.
+		return auth.RevokeUserPersonalTokens(tx, user.ID)
+	})
+	if err != nil {
+		h.renderUser(c, http.StatusInternalServerError, user, gin.H{"error": "Failed to require a password reset"})
+		return
+	}
+	user.PasswordResetRequired = true
+
+	if err := sendResetLink(h.db, h.mail, h.cfg, user, resetRequired); err != nil {
+		log.Printf("password reset for user %d: %v", user.ID, err)
+		h.renderUser(c, http.StatusInternalServerError, user, gin.H{"error": "Password reset required, but the reset email could not be sent"})
+		return
+	}
+	h.renderUser(c, http.StatusOK, user, gin.H{"message": "A password reset link has been sent to " + user.Email + "."})
+}
+
+// ChangeRole assigns one of the existing roles to the user.
+func (h *AdminHandler) ChangeRole(c *gin.Context) {
+	user, ok := h.findUser(c)
+	if !ok || h.refuseSelf(c, user) {
+		return
+	}
+
+	var role models.Role
+	roleID, err := strconv.Atoi(c.PostForm("role_id"))
+	if err != nil || h.db.First(&role, roleID).Error != nil {
+		h.renderUser(c, http.StatusBadRequest, user, gin.H{"error": "Unknown role"})
+		return
+	}
+
+	if err := h.db.Model(&models.User{ID: user.ID}).Update("role_id", role.ID).Error; err != nil {
+		h.renderUser(c, http.StatusInternalServerError, user, gin.H{"error": "Failed to change role"})
+		return
+	}
+	user.RoleID, user.Role = role.ID, role
+	h.renderUser(c, http.StatusOK, user, gin.H{"message": "Role changed to " + role.Name + "."})
+}
+
+func (h *AdminHandler) RevokeSessions(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if err := auth.RevokeUserSessions(h.db, uint(id)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Sessions revoked"})
+}
+
+// Unlock clears the failed login attempts that locked a user's account.
+// Lockouts on the client IP are left to expire on their own.
+func (h *AdminHandler) Unlock(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := h.guard.Unlock(user.Email, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock account: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Account unlocked"})
+}
+
+func (h *AdminHandler) findUser(c *gin.Context) (*models.User, bool) {
+	var user models.User
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || h.db.Preload("Role").First(&user, id).Error != nil {
+		renderHTML(c, http.StatusNotFound, "admin/user.html", gin.H{"title": "User not found", "error": "User not found"})
+		return nil, false
+	}
+	return &user, true
+}
+
+// refuseSelf stops admins from suspending or demoting their own account,
+// which could leave nobody able to undo it.
+func (h *AdminHandler) refuseSelf(c *gin.Context, user *models.User) bool {
+	adminID, _ := c.Get("userID")
+	if id, ok := adminID.(uint); ok && id == user.ID {
+		h.renderUser(c, http.StatusBadRequest, user, gin.H{"error": "You cannot change your own account here"})
+		return true
+	}
+	return false
+}
+
+func (h *AdminHandler) renderUser(c *gin.Context, status int, user *models.User, extra gin.H) {
+	var posts []models.Post
+	var postCount int64
+	h.db.Model(&models.Post{}).Where("author_id = ?", user.ID).Count(&postCount)
+	h.db.Where("author_id = ?", user.ID).Order("created_at desc").Limit(adminActivityLimit).Find(&posts)
+
+	var comments []models.Comment
+	var commentCount int64
+	h.db.Model(&models.Comment{}).Where("author_id = ?", user.ID).Count(&commentCount)
+	h.db.Preload("Post").Where("author_id = ?", user.ID).Order("created_at desc").Limit(adminActivityLimit).Find(&comments)
+
+	var roles []models.Role
+	h.db.Order("id").Find(&roles)
+
+	var invite *models.Invite
+	if user.InviteID != nil {
+		invite = &models.Invite{}
+		if h.db.Preload("CreatedBy").First(invite, *user.InviteID).Error != nil {
+			invite = nil
+		}
+	}
+
+	data := gin.H{
+		"title":         "User " + user.Username,
+		"user":          user,
+		"blocked":       user.Blocked(time.Now()),
+		"posts":         posts,
+		"post_count":    postCount,
+		"comments":      comments,
+		"comment_count": commentCount,
+		"roles":         roles,
+		"invite":        invite,
+		"statuses":      []string{models.UserStatusSuspended, models.UserStatusBanned},
+	}
+	for k, v := range extra {
+		data[k] = v
+	}
+	renderHTML(c, status, "admin/user.html", data)
+}
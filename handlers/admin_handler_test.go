@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Jason-cqtan/simple-blog/auth"
+	"github.com/Jason-cqtan/simple-blog/mailer"
+	"github.com/Jason-cqtan/simple-blog/models"
+	"github.com/Jason-cqtan/simple-blog/throttle"
+)
+
+func TestRequirePasswordResetRevokesAccess(t *testing.T) {
+	db := openTestDB(t)
+	cfg := newTestConfig(t)
+
+	var adminRole models.Role
+	if err := db.Where("name = ?", models.RoleAdmin).First(&adminRole).Error; err != nil {
+		t.Fatal(err)
+	}
+	admin := models.User{Username: "root", Email: "root@example.com", Password: "x", RoleID: adminRole.ID}
+	user := models.User{Username: "dana", Email: "dana@example.com", Password: "x"}
+	for _, u := range []*models.User{&admin, &user} {
+		if err := db.Create(u).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, _, err := auth.CreateSession(db, cfg, &models.Session{UserID: user.ID}); err != nil {
+		t.Fatal(err)
+	}
+	plain, _, err := auth.CreatePersonalToken(db, user.ID, "ci", []string{models.ScopePostsRead}, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mail := mailer.NewMemoryMailer()
+	h := NewAdminHandler(db, cfg, mail, throttle.NewLoginGuard(throttle.NewMemoryStore(), cfg))
+	router := newTestRouter(t)
+	router.POST("/admin/users/:id/password-reset", signInAs(t, db, admin.ID), h.RequirePasswordReset)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, fmt.Sprintf("/admin/users/%d/password-reset", user.ID), nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+
+	var sessions int64
+	db.Model(&models.Session{}).Where("user_id = ? AND revoked_at IS NULL", user.ID).Count(&sessions)
+	if sessions != 0 {
+		t.Errorf("%d sessions still active", sessions)
+	}
+	if _, err := auth.ValidatePersonalToken(db, plain); err == nil {
+		t.Error("personal access token still works")
+	}
+	if len(mail.Messages()) != 1 {
+		t.Errorf("sent %d emails, want the reset link", len(mail.Messages()))
+	}
+}
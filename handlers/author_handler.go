@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Jason-cqtan/simple-blog/config"
+	"github.com/Jason-cqtan/simple-blog/feed"
+	"github.com/Jason-cqtan/simple-blog/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const authorFeedSize = 20
+
+// AuthorHandler serves the public pages of post authors.
+type AuthorHandler struct {
+	db  *gorm.DB
+	cfg *config.Config
+}
+
+func NewAuthorHandler(db *gorm.DB, cfg *config.Config) *AuthorHandler {
+	return &AuthorHandler{db: db, cfg: cfg}
+}
+
+// Show lists an author's published posts, newest first.
+func (h *AuthorHandler) Show(c *gin.Context) {
+	author, ok := h.findAuthor(c)
+	if !ok {
+		renderHTML(c, http.StatusNotFound, "users/author.html", gin.H{"title": "Author not found", "error": "Author not found"})
+		return
+	}
+
+	var total int64
+	h.publishedPosts(author.ID).Model(&models.Post{}).Count(&total)
+	pagination := newPagination(c, total, postsPerPage, maxPostsPerPage)
+
+	var posts []models.Post
+	pagination.Scope(h.publishedPosts(author.ID).Preload("Category").Order("published_at desc")).Find(&posts)
+
+	renderHTML(c, http.StatusOK, "users/author.html", gin.H{
+		"title":      author.Name(),
+		"author":     author,
+		"posts":      posts,
+		"pagination": pagination,
+		"feed":       "/users/" + author.Username + "/feed.xml",
+	})
+}
+
+// Feed serves an Atom feed of the author's latest published posts.
+func (h *AuthorHandler) Feed(c *gin.Context) {
+	author, ok := h.findAuthor(c)
+	if !ok {
+		c.String(http.StatusNotFound, "Author not found")
+		return
+	}
+
+	var posts []models.Post
+	h.publishedPosts(author.ID).Order("published_at desc").Limit(authorFeedSize).Find(&posts)
+
+	pageURL := h.cfg.BaseURL + "/users/" + author.Username
+	person := &feed.Person{Name: author.Name(), URI: pageURL}
+	doc := &feed.Feed{
+		ID:    pageURL,
+		Title: author.Name() + " - Simple Blog",
+		Links: []feed.Link{
+			{Rel: "self", Type: "application/atom+xml", Href: pageURL + "/feed.xml"},
+			{Rel: "alternate", Type: "text/html", Href: pageURL},
+		},
+		Author: person,
+	}
+	for _, post := range posts {
+		// Entry IDs use the numeric URL, which never changes, while the
+		// link points at the current permalink.
+		postURL := h.cfg.BaseURL + post.URL()
+		doc.Entries = append(doc.Entries, feed.Entry{
+			ID:        fmt.Sprintf("%s/posts/%d", h.cfg.BaseURL, post.ID),
+			Title:     post.Title,
+			Updated:   post.UpdatedAt,
+			Published: post.Date(),
+			Links:     []feed.Link{{Rel: "alternate", Type: "text/html", Href: postURL}},
+			Author:    person,
+			Summary:   post.Excerpt,
+			Content:   &feed.Content{Type: "html", Body: string(postContentHTML(h.db, &post))},
+		})
+	}
+
+	body, err := feed.Marshal(doc)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Failed to render feed")
+		return
+	}
+	c.Data(http.StatusOK, feed.ContentType, body)
+}
+
+func (h *AuthorHandler) findAuthor(c *gin.Context) (*models.User, bool) {
+	var author models.User
+	if err := h.db.Where("username = ?", c.Param("username")).First(&author).Error; err != nil {
+		return nil, false
+	}
+	return &author, true
+}
+
+// publishedPosts scopes a query to the author's published posts; drafts,
+// scheduled and archived posts are never shown on public pages.
+func (h *AuthorHandler) publishedPosts(authorID uint) *gorm.DB {
+	return h.db.Where("author_id = ? AND published = ?", authorID, true)
+}
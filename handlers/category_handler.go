@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Jason-cqtan/simple-blog/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var (
+	errCategoryNameTooLong        = errors.New("name must be at most 100 characters")
+	errCategoryDescriptionTooLong = errors.New("description must be at most 500 characters")
+	errCategoryPosition           = errors.New("position must be a whole number")
+)
+
+// CategoryHandler serves the category archive pages and the admin category
+// management.
+type CategoryHandler struct {
+	db *gorm.DB
+}
+
+func NewCategoryHandler(db *gorm.DB) *CategoryHandler {
+	return &CategoryHandler{db: db}
+}
+
+// Index shows the category tree.
+func (h *CategoryHandler) Index(c *gin.Context) {
+	categories, _ := models.CategoryTree(h.db)
+	renderHTML(c, http.StatusOK, "categories/index.html", gin.H{
+		"title":      "Categories",
+		"categories": categories,
+	})
+}
+
+// Show lists the published posts filed under a category or any of its
+// subcategories, newest first.
+func (h *CategoryHandler) Show(c *gin.Context) {
+	var category models.Category
+	if err := h.db.Where("slug = ?", c.Param("slug")).First(&category).Error; err != nil {
+		renderHTML(c, http.StatusNotFound, "categories/show.html", gin.H{"title": "Category not found", "error": "Category not found"})
+		return
+	}
+	breadcrumbs, _ := models.CategoryPath(h.db, category.ID)
+	ids, _ := models.CategorySubtreeIDs(h.db, category.ID)
+
+	var children []models.Category
+	h.db.Where("parent_id = ?", category.ID).Order("position, name").Find(&children)
+
+	filed := func() *gorm.DB {
+		return h.db.Model(&models.Post{}).Where("category_id IN ? AND published = ?", ids, true)
+	}
+	var total int64
+	filed().Count(&total)
+	pagination := newPagination(c, total, postsPerPage, maxPostsPerPage)
+
+	var posts []models.Post
+	pagination.Scope(filed().Preload("Author").Preload("Category").Order("published_at desc")).Find(&posts)
+
+	renderHTML(c, http.StatusOK, "categories/show.html", gin.H{
+		"title":       category.Name,
+		"category":    category,
+		"breadcrumbs": breadcrumbs,
+		"children":    children,
+		"posts":       posts,
+		"pagination":  pagination,
+	})
+}
+
+// AdminList shows the category tree with a form for adding a category.
+func (h *CategoryHandler) AdminList(c *gin.Context) {
+	h.renderAdminList(c, http.StatusOK, gin.H{})
+}
+
+// Create adds a category.
+func (h *CategoryHandler) Create(c *gin.Context) {
+	var category models.Category
+	if err := h.bind(c, &category); err != nil {
+		h.renderAdminList(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := models.SaveCategory(h.db, &category); err != nil {
+		h.renderAdminList(c, categoryErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.Redirect(http.StatusFound, "/admin/categories")
+}
+
+// ShowEditForm shows the form for changing a category.
+func (h *CategoryHandler) ShowEditForm(c *gin.Context) {
+	category, ok := h.findCategory(c)
+	if !ok {
+		return
+	}
+	h.renderEditForm(c, http.StatusOK, category, gin.H{})
+}
+
+// Update changes a category's name, description, position and parent.
+func (h *CategoryHandler) Update(c *gin.Context) {
+	category, ok := h.findCategory(c)
+	if !ok {
+		return
+	}
+	if err := h.bind(c, category); err != nil {
+		h.renderEditForm(c, http.StatusBadRequest, category, gin.H{"error": err.Error()})
+		return
+	}
+	if err := models.SaveCategory(h.db, category); err != nil {
+		h.renderEditForm(c, categoryErrorStatus(err), category, gin.H{"error": err.Error()})
+		return
+	}
+	c.Redirect(http.StatusFound, "/admin/categories")
+}
+
+// Delete removes a category; its subcategories and posts move up to its
+// parent.
+func (h *CategoryHandler) Delete(c *gin.Context) {
+	category, ok := h.findCategory(c)
+	if !ok {
+		return
+	}
+	if err := models.DeleteCategory(h.db, category); err != nil {
+		h.renderAdminList(c, http.StatusInternalServerError, gin.H{"error": "Failed to delete category"})
+		return
+	}
+	c.Redirect(http.StatusFound, "/admin/categories")
+}
+
+// bind reads the category form into category.
+func (h *CategoryHandler) bind(c *gin.Context, category *models.Category) error {
+	category.Name = c.PostForm("name")
+	category.Description = strings.TrimSpace(c.PostForm("description"))
+	if utf8.RuneCountInString(category.Name) > 100 {
+		return errCategoryNameTooLong
+	}
+	if utf8.RuneCountInString(category.Description) > 500 {
+		return errCategoryDescriptionTooLong
+	}
+
+	position, err := strconv.Atoi(c.DefaultPostForm("position", "0"))
+	if err != nil {
+		return errCategoryPosition
+	}
+	category.Position = position
+
+	category.ParentID = nil
+	if value := c.PostForm("parent_id"); value != "" {
+		parentID, err := strconv.Atoi(value)
+		if err != nil {
+			return models.ErrCategoryParent
+		}
+		parent := uint(parentID)
+		category.ParentID = &parent
+	}
+	return nil
+}
+
+func (h *CategoryHandler) findCategory(c *gin.Context) (*models.Category, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	var category models.Category
+	if err != nil || h.db.First(&category, id).Error != nil {
+		h.renderAdminList(c, http.StatusNotFound, gin.H{"error": "Category not found"})
+		return nil, false
+	}
+	return &category, true
+}
+
+func (h *CategoryHandler) renderAdminList(c *gin.Context, status int, extra gin.H) {
+	categories, _ := models.CategoryTree(h.db)
+
+	counts := make(map[uint]int64)
+	var rows []struct {
+		CategoryID uint
+		Posts      int64
+	}
+	h.db.Model(&models.Post{}).Select("category_id, COUNT(*) AS posts").
+		Where("category_id IS NOT NULL").Group("category_id").Scan(&rows)
+	for _, r := range rows {
+		counts[r.CategoryID] = r.Posts
+	}
+
+	data := gin.H{
+		"title":      "Categories",
+		"categories": categories,
+		"counts":     counts,
+	}
+	for k, v := range extra {
+		data[k] = v
+	}
+	renderHTML(c, status, "admin/categories.html", data)
+}
+
+func (h *CategoryHandler) renderEditForm(c *gin.Context, status int, category *models.Category, extra gin.H) {
+	categories, _ := models.CategoryTree(h.db)
+	data := gin.H{
+		"title":      "Edit category",
+		"category":   category,
+		"categories": categories,
+	}
+	for k, v := range extra {
+		data[k] = v
+	}
+	renderHTML(c, status, "admin/category.html", data)
+}
+
+// categoryErrorStatus is the response status for an error from
+// models.SaveCategory.
+func categoryErrorStatus(err error) int {
+	if errors.Is(err, models.ErrCategoryNameEmpty) || errors.Is(err, models.ErrCategoryExists) || errors.Is(err, models.ErrCategoryParent) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
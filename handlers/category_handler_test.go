@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Jason-cqtan/simple-blog/models"
+)
+
+func TestCategoryCreateLengthLimits(t *testing.T) {
+	tests := []struct {
+		name        string
+		catName     string
+		description string
+		want        int
+	}{
+		{name: "100 multibyte characters", catName: strings.Repeat("é", 100), want: http.StatusFound},
+		{name: "101 characters", catName: strings.Repeat("é", 101), want: http.StatusBadRequest},
+		{name: "500 character description", catName: "Café", description: strings.Repeat("ü", 500), want: http.StatusFound},
+		{name: "501 character description", catName: "Café", description: strings.Repeat("ü", 501), want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t)
+			router := newTestRouter(t)
+			router.POST("/admin/categories", NewCategoryHandler(db).Create)
+
+			form := url.Values{"name": {tt.catName}, "description": {tt.description}}
+			req := httptest.NewRequest(http.MethodPost, "/admin/categories", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d\n%s", rec.Code, tt.want, rec.Body.String())
+			}
+			var count int64
+			db.Model(&models.Category{}).Count(&count)
+			if created := count == 1; created != (tt.want == http.StatusFound) {
+				t.Errorf("created = %v", created)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/Jason-cqtan/simple-blog/auth"
 	"github.com/Jason-cqtan/simple-blog/models"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -24,6 +25,13 @@ func (h *CommentHandler) Create(c *gin.Context) {
 		return
 	}
 
+	// Only published posts take comments; drafts stay invisible.
+	var post models.Post
+	if err := h.db.Select("id", "published").First(&post, postID).Error; err != nil || !post.Published {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return
+	}
+
 	userID, _ := c.Get("userID")
 	content := c.PostForm("content")
 
@@ -45,3 +53,28 @@ func (h *CommentHandler) Create(c *gin.Context) {
 
 	c.Redirect(http.StatusFound, "/posts/"+strconv.Itoa(postID))
 }
+
+func (h *CommentHandler) Delete(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid comment ID"})
+		return
+	}
+
+	var comment models.Comment
+	if err := h.db.First(&comment, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return
+	}
+
+	if !auth.CanModify(c, comment.AuthorID, models.PermCommentDeleteOwn, models.PermCommentDeleteAny) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	if err := h.db.Delete(&models.Comment{}, id).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete comment: " + err.Error()})
+		return
+	}
+	c.Redirect(http.StatusFound, "/posts/"+strconv.Itoa(int(comment.PostID)))
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"io/fs"
+	"path/filepath"
+	"testing"
+
+	"github.com/Jason-cqtan/simple-blog/auth"
+	"github.com/Jason-cqtan/simple-blog/config"
+	"github.com/Jason-cqtan/simple-blog/database"
+	"github.com/Jason-cqtan/simple-blog/internal/testdb"
+	"github.com/Jason-cqtan/simple-blog/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// newTestConfig returns the default configuration with plain-HTTP cookies.
+func newTestConfig(t *testing.T) *config.Config {
+	t.Helper()
+	cfg := config.LoadConfig()
+	cfg.SecureCookie = false
+	return cfg
+}
+
+// openTestDB returns an empty in-memory database with the full schema and
+// the default roles.
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db := testdb.Open(t,
+		&models.Role{},
+		&models.Permission{},
+		&models.User{},
+		&models.Post{},
+		&models.PostSlug{},
+		&models.PostRevision{},
+		&models.Tag{},
+		&models.Category{},
+		&models.Comment{},
+		&models.Session{},
+		&models.PasswordReset{},
+		&models.RecoveryCode{},
+		&models.UserIdentity{},
+		&models.PersonalAccessToken{},
+		&models.LoginAttempt{},
+		&models.Invite{},
+	)
+	if err := database.EnsureRoles(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// newTestRouter returns a router with the views loaded.
+func newTestRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	var htmlFiles []string
+	err := filepath.WalkDir("../views", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && filepath.Ext(path) == ".html" {
+			htmlFiles = append(htmlFiles, path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	router.LoadHTMLFiles(htmlFiles...)
+	return router
+}
+
+// signInAs returns middleware that authenticates every request as userID,
+// with the permissions of their role.
+func signInAs(t *testing.T, db *gorm.DB, userID uint) gin.HandlerFunc {
+	t.Helper()
+	user, err := auth.LoadUser(db, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return func(c *gin.Context) {
+		c.Set("userID", user.ID)
+		auth.SetUser(c, user)
+	}
+}
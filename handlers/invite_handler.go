@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Jason-cqtan/simple-blog/auth"
+	"github.com/Jason-cqtan/simple-blog/config"
+	"github.com/Jason-cqtan/simple-blog/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Limits on the invites users can create.
+const (
+	maxInviteLifetimeDays = 90
+	maxInviteUses         = 100
+	adminInvitesPerPage   = 50
+)
+
+// InviteHandler lets users create invite codes for invite-only registration.
+type InviteHandler struct {
+	db  *gorm.DB
+	cfg *config.Config
+}
+
+func NewInviteHandler(db *gorm.DB, cfg *config.Config) *InviteHandler {
+	return &InviteHandler{db: db, cfg: cfg}
+}
+
+func (h *InviteHandler) List(c *gin.Context) {
+	h.render(c, http.StatusOK, gin.H{})
+}
+
+// Create issues an invite usable max_uses times within expires_in_days.
+func (h *InviteHandler) Create(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	note := strings.TrimSpace(c.PostForm("note"))
+	if len(note) > 255 {
+		h.render(c, http.StatusBadRequest, gin.H{"error": "Note must be at most 255 characters"})
+		return
+	}
+	maxUses, err := strconv.Atoi(c.DefaultPostForm("max_uses", "1"))
+	if err != nil || maxUses < 1 || maxUses > maxInviteUses {
+		h.render(c, http.StatusBadRequest, gin.H{"error": "Uses must be between 1 and 100"})
+		return
+	}
+	days, err := strconv.Atoi(c.DefaultPostForm("expires_in_days", "7"))
+	if err != nil || days < 1 || days > maxInviteLifetimeDays {
+		h.render(c, http.StatusBadRequest, gin.H{"error": "Expiry must be between 1 and 90 days"})
+		return
+	}
+
+	code, _, err := auth.CreateInvite(h.db, userID.(uint), note, maxUses, time.Now().AddDate(0, 0, days))
+	if err != nil {
+		h.render(c, http.StatusInternalServerError, gin.H{"error": "Failed to create invite"})
+		return
+	}
+
+	h.render(c, http.StatusOK, gin.H{
+		"new_code": code,
+		"new_link": h.cfg.BaseURL + "/register?invite=" + url.QueryEscape(code),
+	})
+}
+
+// Revoke revokes one of the user's own invites.
+func (h *InviteHandler) Revoke(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		h.render(c, http.StatusBadRequest, gin.H{"error": "Invalid invite ID"})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	if err := auth.RevokeInvite(h.db, userID.(uint), uint(id), false); err != nil {
+		h.render(c, http.StatusNotFound, gin.H{"error": "Invite not found"})
+		return
+	}
+	c.Redirect(http.StatusFound, "/profile/invites")
+}
+
+// RevokeAny lets admins revoke anyone's invite.
+func (h *InviteHandler) RevokeAny(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invite ID"})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	if err := auth.RevokeInvite(h.db, userID.(uint), uint(id), true); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invite not found"})
+		return
+	}
+	c.Redirect(http.StatusFound, "/admin/invites")
+}
+
+// ListAll shows the invites of all users, newest first, for admins.
+func (h *InviteHandler) ListAll(c *gin.Context) {
+	var total int64
+	h.db.Model(&models.Invite{}).Count(&total)
+	pagination := newPagination(c, total, adminInvitesPerPage, adminMaxPerPage)
+
+	var invites []models.Invite
+	pagination.Scope(h.db.Preload("CreatedBy").Order("created_at desc")).Find(&invites)
+
+	renderHTML(c, http.StatusOK, "admin/invites.html", gin.H{
+		"title":       "Invites",
+		"invites":     invites,
+		"pagination":  pagination,
+		"invite_only": h.cfg.RegistrationMode == config.RegistrationInvite,
+	})
+}
+
+func (h *InviteHandler) render(c *gin.Context, status int, extra gin.H) {
+	userID, _ := c.Get("userID")
+
+	var invites []models.Invite
+	h.db.Where("created_by_id = ? AND revoked_at IS NULL", userID).Order("created_at desc").Find(&invites)
+
+	data := gin.H{
+		"title":       "Invites",
+		"invites":     invites,
+		"invite_only": h.cfg.RegistrationMode == config.RegistrationInvite,
+	}
+	for k, v := range extra {
+		data[k] = v
+	}
+	renderHTML(c, status, "users/invites.html", data)
+}
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/Jason-cqtan/simple-blog/config"
+	"github.com/gin-gonic/gin"
+)
+
+// JWKSHandler publishes the public signing keys, so other services can
+// verify tokens issued by the blog.
+type JWKSHandler struct {
+	cfg *config.Config
+}
+
+func NewJWKSHandler(cfg *config.Config) *JWKSHandler {
+	return &JWKSHandler{cfg: cfg}
+}
+
+func (h *JWKSHandler) Show(c *gin.Context) {
+	set, err := h.cfg.Keyring.JWKS()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode keys"})
+		return
+	}
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, set)
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+	"slices"
+
+	"github.com/Jason-cqtan/simple-blog/auth"
+	"github.com/Jason-cqtan/simple-blog/config"
+	"github.com/Jason-cqtan/simple-blog/models"
+	"github.com/Jason-cqtan/simple-blog/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/skip2/go-qrcode"
+	"gorm.io/gorm"
+)
+
+// totpIssuer is the account label shown in authenticator apps.
+const totpIssuer = "Simple Blog"
+
+type MFAHandler struct {
+	db  *gorm.DB
+	cfg *config.Config
+}
+
+func NewMFAHandler(db *gorm.DB, cfg *config.Config) *MFAHandler {
+	return &MFAHandler{db: db, cfg: cfg}
+}
+
+func (h *MFAHandler) Show(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	// Keep the pending secret across page loads so a half-finished
+	// enrollment in the authenticator app stays valid.
+	if !user.TOTPEnabled && user.TOTPSecret == "" {
+		secret, err := utils.GenerateTOTPSecret()
+		if err != nil {
+			h.render(c, http.StatusInternalServerError, user, gin.H{"error": "Failed to generate secret"})
+			return
+		}
+		if err := h.db.Model(user).Updates(map[string]interface{}{"totp_secret": secret, "totp_last_step": 0}).Error; err != nil {
+			h.render(c, http.StatusInternalServerError, user, gin.H{"error": "Failed to save secret"})
+			return
+		}
+		user.TOTPSecret = secret
+	}
+
+	h.render(c, http.StatusOK, user, nil)
+}
+
+func (h *MFAHandler) QRCode(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+	if user.TOTPEnabled || user.TOTPSecret == "" {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	png, err := qrcode.Encode(utils.TOTPURI(totpIssuer, user.Email, user.TOTPSecret), qrcode.Medium, 256)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "image/png", png)
+}
+
+func (h *MFAHandler) Enable(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+	if user.TOTPEnabled {
+		h.render(c, http.StatusBadRequest, user, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+	if !auth.VerifyTOTP(h.db, user, c.PostForm("code")) {
+		h.render(c, http.StatusBadRequest, user, gin.H{"error": "Invalid authentication code"})
+		return
+	}
+
+	if err := h.db.Model(user).Update("totp_enabled", true).Error; err != nil {
+		h.render(c, http.StatusInternalServerError, user, gin.H{"error": "Failed to enable two-factor authentication"})
+		return
+	}
+	user.TOTPEnabled = true
+
+	codes, err := auth.ReplaceRecoveryCodes(h.db, user.ID)
+	if err != nil {
+		h.render(c, http.StatusInternalServerError, user, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+
+	// The user just proved possession of the second factor, so swap the
+	// current session for one that carries the 2FA flag.
+	sessionID, _ := c.Get("sessionID")
+	_ = auth.RevokeSession(h.db, sessionID.(uint))
+	if err := startSession(c, h.db, h.cfg, user.ID, true); err != nil {
+		h.render(c, http.StatusInternalServerError, user, gin.H{"error": "Failed to refresh session"})
+		return
+	}
+
+	h.render(c, http.StatusOK, user, gin.H{
+		"message":        "Two-factor authentication is now enabled.",
+		"recovery_codes": codes,
+	})
+}
+
+func (h *MFAHandler) Disable(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+	if !user.TOTPEnabled {
+		h.render(c, http.StatusBadRequest, user, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+	if !user.CheckPassword(c.PostForm("password")) || !auth.VerifySecondFactor(h.db, user, c.PostForm("code")) {
+		h.render(c, http.StatusBadRequest, user, gin.H{"error": "Invalid password or authentication code"})
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"totp_enabled":   false,
+			"totp_secret":    "",
+			"totp_last_step": 0,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error
+	})
+	if err != nil {
+		h.render(c, http.StatusInternalServerError, user, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/profile/2fa")
+}
+
+func (h *MFAHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+	if !user.TOTPEnabled || !auth.VerifyTOTP(h.db, user, c.PostForm("code")) {
+		h.render(c, http.StatusBadRequest, user, gin.H{"error": "Invalid authentication code"})
+		return
+	}
+
+	codes, err := auth.ReplaceRecoveryCodes(h.db, user.ID)
+	if err != nil {
+		h.render(c, http.StatusInternalServerError, user, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+	h.render(c, http.StatusOK, user, gin.H{
+		"message":        "New recovery codes generated. Your old codes no longer work.",
+		"recovery_codes": codes,
+	})
+}
+
+func (h *MFAHandler) currentUser(c *gin.Context) (*models.User, bool) {
+	userID, _ := c.Get("userID")
+	var user models.User
+	if err := h.db.First(&user, userID).Error; err != nil {
+		renderHTML(c, http.StatusNotFound, "users/two_factor.html", gin.H{"title": "Two-Factor Authentication", "error": "User not found"})
+		return nil, false
+	}
+	return &user, true
+}
+
+func (h *MFAHandler) render(c *gin.Context, status int, user *models.User, extra gin.H) {
+	data := gin.H{
+		"title":    "Two-Factor Authentication",
+		"enabled":  user.TOTPEnabled,
+		"mfa":      c.GetBool("mfa"),
+		"required": slices.Contains(h.cfg.MFARequiredRoles, c.GetString("role")),
+	}
+	if user.TOTPEnabled {
+		var remaining int64
+		h.db.Model(&models.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", user.ID).Count(&remaining)
+		data["remaining_codes"] = remaining
+	} else if user.TOTPSecret != "" {
+		data["secret"] = user.TOTPSecret
+		// html/template would otherwise reject the otpauth: scheme.
+		data["otpauth_uri"] = template.URL(utils.TOTPURI(totpIssuer, user.Email, user.TOTPSecret))
+	}
+	for k, v := range extra {
+		data[k] = v
+	}
+	renderHTML(c, status, "users/two_factor.html", data)
+}
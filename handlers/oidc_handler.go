@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Jason-cqtan/simple-blog/config"
+	"github.com/Jason-cqtan/simple-blog/models"
+	"github.com/Jason-cqtan/simple-blog/oidc"
+	"github.com/Jason-cqtan/simple-blog/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// oidcStateCookie carries state, nonce and PKCE verifier between the
+	// redirect to the provider and the callback.
+	oidcStateCookie = "oidc_state"
+	oidcStateTTL    = 10 * time.Minute
+)
+
+var (
+	errOIDCEmailUnverified = errors.New("your identity provider did not confirm your email address")
+	errOIDCNoAccount       = errors.New("no account exists for your email address")
+	// An unverified account may have been registered by someone else with
+	// the address, so it is never linked automatically.
+	errOIDCAccountUnverified = errors.New("the account with your email address has not confirmed it; sign in with its password and verify the address first")
+)
+
+var usernameInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9_.\-]+`)
+
+type OIDCHandler struct {
+	db       *gorm.DB
+	cfg      *config.Config
+	provider *oidc.Provider
+}
+
+func NewOIDCHandler(db *gorm.DB, cfg *config.Config, provider *oidc.Provider) *OIDCHandler {
+	return &OIDCHandler{db: db, cfg: cfg, provider: provider}
+}
+
+func (h *OIDCHandler) Login(c *gin.Context) {
+	state, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		h.fail(c, http.StatusInternalServerError, "Failed to start single sign-on")
+		return
+	}
+	nonce, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		h.fail(c, http.StatusInternalServerError, "Failed to start single sign-on")
+		return
+	}
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		h.fail(c, http.StatusInternalServerError, "Failed to start single sign-on")
+		return
+	}
+
+	authURL, err := h.provider.AuthCodeURL(state, nonce, challenge)
+	if err != nil {
+		log.Printf("oidc login: %v", err)
+		h.fail(c, http.StatusBadGateway, "Single sign-on is currently unavailable")
+		return
+	}
+
+	// The random values are base64url, so "." is a safe separator.
+	c.SetCookie(oidcStateCookie, state+"."+nonce+"."+verifier, int(oidcStateTTL.Seconds()), "/auth/oidc", "", h.cfg.SecureCookie, true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	cookie, err := c.Cookie(oidcStateCookie)
+	c.SetCookie(oidcStateCookie, "", -1, "/auth/oidc", "", h.cfg.SecureCookie, true)
+	if err != nil {
+		h.fail(c, http.StatusBadRequest, "Your sign-in attempt expired, please try again")
+		return
+	}
+	parts := strings.Split(cookie, ".")
+	if len(parts) != 3 {
+		h.fail(c, http.StatusBadRequest, "Your sign-in attempt expired, please try again")
+		return
+	}
+	state, nonce, verifier := parts[0], parts[1], parts[2]
+
+	if c.Query("error") != "" {
+		h.fail(c, http.StatusUnauthorized, "Sign-in was cancelled or denied by the identity provider")
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(c.Query("state")), []byte(state)) != 1 {
+		h.fail(c, http.StatusBadRequest, "Invalid sign-in state, please try again")
+		return
+	}
+
+	idToken, err := h.provider.Exchange(c.Query("code"), verifier)
+	if err != nil {
+		log.Printf("oidc callback: %v", err)
+		h.fail(c, http.StatusBadGateway, "Single sign-on failed")
+		return
+	}
+	claims, err := h.provider.VerifyIDToken(idToken, nonce)
+	if err != nil {
+		log.Printf("oidc callback: %v", err)
+		h.fail(c, http.StatusUnauthorized, "Single sign-on failed")
+		return
+	}
+
+	user, err := h.findOrCreateUser(claims)
+	if errors.Is(err, errOIDCEmailUnverified) || errors.Is(err, errOIDCNoAccount) || errors.Is(err, errOIDCAccountUnverified) {
+		h.fail(c, http.StatusForbidden, "Cannot sign in: "+err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("oidc callback: %v", err)
+		h.fail(c, http.StatusInternalServerError, "Failed to sign in")
+		return
+	}
+
+	if user.Blocked(time.Now()) {
+		h.fail(c, http.StatusForbidden, user.BlockedMessage())
+		return
+	}
+
+	// Accounts with local 2FA still go through the second step.
+	if user.TOTPEnabled {
+		mfaToken, err := utils.GenerateMFAToken(user.ID, h.cfg.Keyring, mfaLoginTTL)
+		if err != nil {
+			h.fail(c, http.StatusInternalServerError, "Failed to generate token")
+			return
+		}
+		renderHTML(c, http.StatusOK, "users/login_2fa.html", gin.H{"title": "Two-Factor Authentication", "mfa_token": mfaToken})
+		return
+	}
+
+	if err := startSession(c, h.db, h.cfg, user.ID, providerDidMFA(claims)); err != nil {
+		h.fail(c, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+	c.Redirect(http.StatusFound, "/")
+}
+
+// findOrCreateUser resolves the provider identity to a local account: an
+// existing link first, then an account with the same verified email, and
+// finally a new account if auto-registration is enabled.
+func (h *OIDCHandler) findOrCreateUser(claims *oidc.IDClaims) (*models.User, error) {
+	var user models.User
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var identity models.UserIdentity
+		err := tx.Where("issuer = ? AND subject = ?", claims.Issuer, claims.Subject).First(&identity).Error
+		if err == nil {
+			return tx.First(&user, identity.UserID).Error
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if claims.Email == "" || !claims.EmailVerified {
+			return errOIDCEmailUnverified
+		}
+
+		err = tx.Where("LOWER(email) = ?", strings.ToLower(claims.Email)).First(&user).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			// Single sign-on must not bypass closed or invite-only sign-up.
+			if !h.cfg.OIDCAutoRegister || h.cfg.RegistrationMode != config.RegistrationOpen {
+				return errOIDCNoAccount
+			}
+			if err := h.createUser(tx, claims, &user); err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		case !user.EmailVerified():
+			return errOIDCAccountUnverified
+		}
+
+		return tx.Create(&models.UserIdentity{
+			UserID:  user.ID,
+			Issuer:  claims.Issuer,
+			Subject: claims.Subject,
+			Email:   claims.Email,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (h *OIDCHandler) createUser(tx *gorm.DB, claims *oidc.IDClaims, user *models.User) error {
+	username, err := uniqueUsername(tx, claims)
+	if err != nil {
+		return err
+	}
+
+	// SSO accounts have no password; the user can set one through the
+	// password reset flow if they ever need local login.
+	now := time.Now()
+	*user = models.User{
+		Username:        username,
+		Email:           claims.Email,
+		EmailVerifiedAt: &now,
+	}
+	return tx.Create(user).Error
+}
+
+// uniqueUsername derives a username from the ID token, appending a number
+// if it is already taken.
+func uniqueUsername(tx *gorm.DB, claims *oidc.IDClaims) (string, error) {
+	base := claims.PreferredUsername
+	if base == "" {
+		base, _, _ = strings.Cut(claims.Email, "@")
+	}
+	base = usernameInvalidChars.ReplaceAllString(base, "")
+	if len(base) > 90 {
+		base = base[:90]
+	}
+	for len(base) < 3 {
+		base += "_"
+	}
+
+	candidate := base
+	for i := 2; i < 1000; i++ {
+		var count int64
+		if err := tx.Model(&models.User{}).Where("username = ?", candidate).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 && !models.IsReservedUsername(candidate) {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s%d", base, i)
+	}
+	return "", errors.New("could not find a free username")
+}
+
+// providerDidMFA reports whether the ID token's amr claim (RFC 8176) shows
+// that the provider performed multi-factor authentication.
+func providerDidMFA(claims *oidc.IDClaims) bool {
+	return slices.Contains(claims.AMR, "mfa") || slices.Contains(claims.AMR, "otp") || slices.Contains(claims.AMR, "hwk")
+}
+
+func (h *OIDCHandler) fail(c *gin.Context, status int, msg string) {
+	renderHTML(c, status, "users/login.html", gin.H{"title": "Login", "error": msg, "sso_name": h.cfg.OIDCProviderName})
+}
@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Jason-cqtan/simple-blog/auth"
+	"github.com/Jason-cqtan/simple-blog/config"
+	"github.com/Jason-cqtan/simple-blog/models"
+	"github.com/Jason-cqtan/simple-blog/oidc"
+	"github.com/Jason-cqtan/simple-blog/oidc/oidctest"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type oidcTestEnv struct {
+	router *gin.Engine
+	db     *gorm.DB
+	cfg    *config.Config
+	idp    *oidctest.Server
+}
+
+func newOIDCTestEnv(t *testing.T) *oidcTestEnv {
+	t.Helper()
+	idp := oidctest.NewServer("blog", "s3cret")
+	t.Cleanup(idp.Close)
+
+	cfg := newTestConfig(t)
+	cfg.OIDCIssuer = idp.Issuer()
+	cfg.OIDCClientID = idp.ClientID
+	cfg.OIDCClientSecret = idp.ClientSecret
+	cfg.OIDCRedirectURL = "http://blog.test/auth/oidc/callback"
+	cfg.OIDCAutoRegister = true
+	cfg.RegistrationMode = config.RegistrationOpen
+
+	env := &oidcTestEnv{router: newTestRouter(t), db: openTestDB(t), cfg: cfg, idp: idp}
+	h := NewOIDCHandler(env.db, cfg, oidc.NewProvider(oidc.Config{
+		Issuer:       cfg.OIDCIssuer,
+		ClientID:     cfg.OIDCClientID,
+		ClientSecret: cfg.OIDCClientSecret,
+		RedirectURL:  cfg.OIDCRedirectURL,
+	}))
+	env.router.GET("/auth/oidc/login", h.Login)
+	env.router.GET("/auth/oidc/callback", h.Callback)
+	return env
+}
+
+// signIn runs the login flow up to the callback. tamper may change the
+// state cookie and the callback query before the callback is requested.
+func (env *oidcTestEnv) signIn(t *testing.T, tamper func(cookie *http.Cookie, query url.Values)) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	env.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/auth/oidc/login", nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("login: status %d", rec.Code)
+	}
+	var cookie *http.Cookie
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == oidcStateCookie {
+			cookie = c
+		}
+	}
+	if cookie == nil {
+		t.Fatal("login: no state cookie")
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := callback.Query()
+	if tamper != nil {
+		tamper(cookie, query)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/auth/oidc/callback?"+query.Encode(), nil)
+	if cookie.Value != "" {
+		req.AddCookie(&http.Cookie{Name: cookie.Name, Value: cookie.Value})
+	}
+	rec = httptest.NewRecorder()
+	env.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func hasSessionCookie(rec *httptest.ResponseRecorder) bool {
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == auth.AccessCookie && c.Value != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func TestOIDCCallback(t *testing.T) {
+	replaceCookiePart := func(i int, value string) func(*http.Cookie, url.Values) {
+		return func(cookie *http.Cookie, _ url.Values) {
+			parts := strings.Split(cookie.Value, ".")
+			parts[i] = value
+			cookie.Value = strings.Join(parts, ".")
+		}
+	}
+
+	tests := []struct {
+		name     string
+		setup    func(env *oidcTestEnv)
+		tamper   func(cookie *http.Cookie, query url.Values)
+		want     int
+		wantBody string
+		wantUser bool // an account for the identity exists afterwards
+	}{
+		{name: "registers a new account", want: http.StatusFound, wantUser: true},
+		{
+			name: "links an existing account",
+			setup: func(env *oidcTestEnv) {
+				env.cfg.OIDCAutoRegister = false
+				verified := time.Now()
+				env.db.Create(&models.User{Username: "existing", Email: "SSO.User@example.com", Password: "x", EmailVerifiedAt: &verified})
+			},
+			want:     http.StatusFound,
+			wantUser: true,
+		},
+		{
+			// Someone else may have registered the address without owning it.
+			name: "does not link an unverified account",
+			setup: func(env *oidcTestEnv) {
+				env.db.Create(&models.User{Username: "squatter", Email: "sso.user@example.com", Password: "x"})
+			},
+			want:     http.StatusForbidden,
+			wantBody: "has not confirmed it",
+		},
+		{
+			name:     "bad state",
+			tamper:   func(_ *http.Cookie, q url.Values) { q.Set("state", "forged") },
+			want:     http.StatusBadRequest,
+			wantBody: "Invalid sign-in state",
+		},
+		{
+			name:     "missing state cookie",
+			tamper:   func(c *http.Cookie, _ url.Values) { c.Value = "" },
+			want:     http.StatusBadRequest,
+			wantBody: "sign-in attempt expired",
+		},
+		{
+			name:     "bad nonce",
+			tamper:   replaceCookiePart(1, "forged-nonce"),
+			want:     http.StatusUnauthorized,
+			wantBody: "Single sign-on failed",
+		},
+		{
+			name:     "bad PKCE verifier",
+			tamper:   replaceCookiePart(2, "forged-verifier"),
+			want:     http.StatusBadGateway,
+			wantBody: "Single sign-on failed",
+		},
+		{
+			name:     "expired ID token",
+			setup:    func(env *oidcTestEnv) { env.idp.SetTokenTTL(-time.Hour) },
+			want:     http.StatusUnauthorized,
+			wantBody: "Single sign-on failed",
+		},
+		{
+			name:     "provider error",
+			tamper:   func(_ *http.Cookie, q url.Values) { q.Set("error", "access_denied") },
+			want:     http.StatusUnauthorized,
+			wantBody: "cancelled or denied",
+		},
+		{
+			name:     "auto-registration off",
+			setup:    func(env *oidcTestEnv) { env.cfg.OIDCAutoRegister = false },
+			want:     http.StatusForbidden,
+			wantBody: "no account exists",
+		},
+		{
+			name:     "registration closed",
+			setup:    func(env *oidcTestEnv) { env.cfg.RegistrationMode = config.RegistrationClosed },
+			want:     http.StatusForbidden,
+			wantBody: "no account exists",
+		},
+		{
+			name: "unverified email",
+			setup: func(env *oidcTestEnv) {
+				env.idp.SetIdentity(oidctest.Identity{Subject: "user-1", Email: "sso.user@example.com"})
+			},
+			want:     http.StatusForbidden,
+			wantBody: "did not confirm your email",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newOIDCTestEnv(t)
+			if tt.setup != nil {
+				tt.setup(env)
+			}
+
+			rec := env.signIn(t, tt.tamper)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d\n%s", rec.Code, tt.want, rec.Body.String())
+			}
+			if tt.wantBody != "" && !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body does not mention %q", tt.wantBody)
+			}
+			if got := hasSessionCookie(rec); got != (rec.Code == http.StatusFound) {
+				t.Errorf("session cookie set = %v", got)
+			}
+
+			var identities int64
+			env.db.Model(&models.UserIdentity{}).Where("issuer = ? AND subject = ?", env.idp.Issuer(), "user-1").Count(&identities)
+			var users int64
+			env.db.Model(&models.User{}).Where("LOWER(email) = ?", "sso.user@example.com").Count(&users)
+			if tt.wantUser && (identities != 1 || users != 1) {
+				t.Errorf("identities = %d, users = %d; want one linked account", identities, users)
+			}
+			if !tt.wantUser && identities != 0 {
+				t.Errorf("identities = %d, want none", identities)
+			}
+		})
+	}
+}
+
+func TestOIDCCallbackReturningUser(t *testing.T) {
+	env := newOIDCTestEnv(t)
+	if rec := env.signIn(t, nil); rec.Code != http.StatusFound {
+		t.Fatalf("first sign-in: status %d", rec.Code)
+	}
+
+	// The link is by subject, so a changed email still finds the account and
+	// auto-registration is no longer needed.
+	env.cfg.OIDCAutoRegister = false
+	env.idp.SetIdentity(oidctest.Identity{Subject: "user-1", Email: "renamed@example.com", EmailVerified: true})
+	if rec := env.signIn(t, nil); rec.Code != http.StatusFound {
+		t.Fatalf("second sign-in: status %d\n%s", rec.Code, rec.Body.String())
+	}
+	var users int64
+	env.db.Model(&models.User{}).Count(&users)
+	if users != 1 {
+		t.Errorf("users = %d, want 1", users)
+	}
+}
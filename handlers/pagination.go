@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"net/url"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// paginationWindow is how many page numbers are linked on each side of the
+// current page; the first and last pages are always linked.
+const paginationWindow = 2
+
+// Pagination is one page of a list, read from the "page" and "per_page"
+// query parameters. Templates render it with the "partials/pagination.html"
+// partial.
+type Pagination struct {
+	Page    int
+	PerPage int
+	Pages   int
+	Total   int64
+
+	query url.Values
+}
+
+// newPagination reads the requested page of total items. per_page defaults
+// to perPage and is capped at maxPerPage; pages past the end show the last
+// one.
+func newPagination(c *gin.Context, total int64, perPage, maxPerPage int) *Pagination {
+	if n, err := strconv.Atoi(c.Query("per_page")); err == nil && n > 0 {
+		perPage = min(n, maxPerPage)
+	}
+
+	p := &Pagination{
+		PerPage: perPage,
+		Pages:   int((total + int64(perPage) - 1) / int64(perPage)),
+		Total:   total,
+		query:   c.Request.URL.Query(),
+	}
+	p.Page, _ = strconv.Atoi(c.Query("page"))
+	if p.Page > p.Pages {
+		p.Page = p.Pages
+	}
+	if p.Page < 1 {
+		p.Page = 1
+	}
+	return p
+}
+
+// Scope limits query to the rows of the current page.
+func (p *Pagination) Scope(query *gorm.DB) *gorm.DB {
+	return query.Offset((p.Page - 1) * p.PerPage).Limit(p.PerPage)
+}
+
+// Prev is the previous page number, or 0 on the first page.
+func (p *Pagination) Prev() int {
+	if p.Page > 1 {
+		return p.Page - 1
+	}
+	return 0
+}
+
+// Next is the next page number, or 0 on the last page.
+func (p *Pagination) Next() int {
+	if p.Page < p.Pages {
+		return p.Page + 1
+	}
+	return 0
+}
+
+// Numbers lists the page numbers to link, with 0 marking a gap.
+func (p *Pagination) Numbers() []int {
+	var numbers []int
+	for n := 1; n <= p.Pages; n++ {
+		if n == 1 || n == p.Pages || (n >= p.Page-paginationWindow && n <= p.Page+paginationWindow) {
+			numbers = append(numbers, n)
+		} else if len(numbers) > 0 && numbers[len(numbers)-1] != 0 {
+			numbers = append(numbers, 0)
+		}
+	}
+	return numbers
+}
+
+// URL links to page n, keeping the request's other query parameters, such as
+// a search term or filter.
+func (p *Pagination) URL(n int) string {
+	query := url.Values{}
+	for k, v := range p.query {
+		query[k] = v
+	}
+	query.Set("page", strconv.Itoa(n))
+	return "?" + query.Encode()
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Jason-cqtan/simple-blog/auth"
+	"github.com/Jason-cqtan/simple-blog/config"
+	"github.com/Jason-cqtan/simple-blog/mailer"
+	"github.com/Jason-cqtan/simple-blog/models"
+	"github.com/Jason-cqtan/simple-blog/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// forgotPasswordNotice is shown whether or not the address exists, so the form
+// cannot be used to discover registered accounts.
+const forgotPasswordNotice = "If an account exists for that email, a reset link has been sent."
+
+var errInvalidResetToken = errors.New("invalid or expired reset link")
+
+// resetReason frames the reset email: a user's own request, or a reset
+// required by an administrator.
+type resetReason struct {
+	intro, outro string
+}
+
+var (
+	resetRequested = resetReason{
+		intro: "Someone asked to reset the password for your Simple Blog account.",
+		outro: "If you did not ask for this, you can ignore this email.",
+	}
+	resetRequired = resetReason{
+		intro: "An administrator requires you to choose a new password for your Simple Blog account\n" +
+			"before you can sign in again.",
+		outro: "If you have questions about this, contact an administrator.",
+	}
+)
+
+type PasswordHandler struct {
+	db   *gorm.DB
+	cfg  *config.Config
+	mail mailer.Mailer
+}
+
+func NewPasswordHandler(db *gorm.DB, cfg *config.Config, mail mailer.Mailer) *PasswordHandler {
+	return &PasswordHandler{db: db, cfg: cfg, mail: mail}
+}
+
+func (h *PasswordHandler) ShowForgotForm(c *gin.Context) {
+	renderHTML(c, http.StatusOK, "users/forgot_password.html", gin.H{"title": "Forgot Password"})
+}
+
+func (h *PasswordHandler) Forgot(c *gin.Context) {
+	email := strings.TrimSpace(c.PostForm("email"))
+	if err := utils.ValidateEmail(email); err != nil {
+		renderHTML(c, http.StatusBadRequest, "users/forgot_password.html", gin.H{"title": "Forgot Password", "error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := h.db.Where("email = ?", email).First(&user).Error; err == nil {
+		if err := sendResetLink(h.db, h.mail, h.cfg, &user, resetRequested); err != nil {
+			log.Printf("password reset for user %d: %v", user.ID, err)
+		}
+	}
+
+	renderHTML(c, http.StatusOK, "users/forgot_password.html", gin.H{"title": "Forgot Password", "message": forgotPasswordNotice})
+}
+
+func (h *PasswordHandler) ShowResetForm(c *gin.Context) {
+	token := c.Query("token")
+	if _, err := h.findReset(token); err != nil {
+		renderHTML(c, http.StatusBadRequest, "users/reset_password.html", gin.H{"title": "Reset Password", "error": err.Error()})
+		return
+	}
+	renderHTML(c, http.StatusOK, "users/reset_password.html", gin.H{"title": "Reset Password", "token": token})
+}
+
+func (h *PasswordHandler) Reset(c *gin.Context) {
+	token := c.PostForm("token")
+	password := c.PostForm("password")
+
+	reset, err := h.findReset(token)
+	if err != nil {
+		renderHTML(c, http.StatusBadRequest, "users/reset_password.html", gin.H{"title": "Reset Password", "error": err.Error()})
+		return
+	}
+	if password != c.PostForm("password_confirm") {
+		renderHTML(c, http.StatusBadRequest, "users/reset_password.html", gin.H{"title": "Reset Password", "token": token, "error": "Passwords do not match"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, reset.UserID).Error; err != nil {
+		renderHTML(c, http.StatusBadRequest, "users/reset_password.html", gin.H{"title": "Reset Password", "error": "Invalid or expired reset link"})
+		return
+	}
+	if err := h.cfg.PasswordPolicy.Validate(password, user.Username, user.Email); err != nil {
+		renderHTML(c, http.StatusBadRequest, "users/reset_password.html", gin.H{"title": "Reset Password", "token": token, "error": err.Error()})
+		return
+	}
+	if err := user.HashPassword(h.cfg.PasswordHasher, password); err != nil {
+		renderHTML(c, http.StatusInternalServerError, "users/reset_password.html", gin.H{"title": "Reset Password", "token": token, "error": "Failed to process password"})
+		return
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		// Claim the token first so a concurrent request cannot reuse it.
+		result := tx.Model(&models.PasswordReset{}).
+			Where("id = ? AND used_at IS NULL", reset.ID).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errInvalidResetToken
+		}
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"password":                user.Password,
+			"password_reset_required": false,
+		}).Error; err != nil {
+			return err
+		}
+		// Whoever knew the old password must not stay signed in.
+		return auth.RevokeUserSessions(tx, user.ID)
+	})
+	if err != nil {
+		renderHTML(c, http.StatusBadRequest, "users/reset_password.html", gin.H{"title": "Reset Password", "error": "Failed to reset password"})
+		return
+	}
+
+	renderHTML(c, http.StatusOK, "users/login.html", gin.H{"title": "Login", "message": "Your password has been reset. Please log in."})
+}
+
+func (h *PasswordHandler) findReset(token string) (*models.PasswordReset, error) {
+	if token == "" {
+		return nil, errInvalidResetToken
+	}
+	var reset models.PasswordReset
+	if err := h.db.Where("token_hash = ?", utils.HashToken(token)).First(&reset).Error; err != nil {
+		return nil, errInvalidResetToken
+	}
+	if !reset.Usable() {
+		return nil, errInvalidResetToken
+	}
+	return &reset, nil
+}
+
+// sendResetLink mails user a single-use password reset link, invalidating any
+// earlier one.
+func sendResetLink(db *gorm.DB, mail mailer.Mailer, cfg *config.Config, user *models.User, reason resetReason) error {
+	token, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Only the most recently requested link stays valid.
+		if err := tx.Model(&models.PasswordReset{}).
+			Where("user_id = ? AND used_at IS NULL", user.ID).
+			Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.PasswordReset{
+			UserID:    user.ID,
+			TokenHash: utils.HashToken(token),
+			ExpiresAt: time.Now().Add(cfg.PasswordResetTTL),
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	link := cfg.BaseURL + "/reset-password?token=" + url.QueryEscape(token)
+	return mail.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your Simple Blog password",
+		Body: fmt.Sprintf("Hi %s,\n\n%s\n"+
+			"Open the link below within %s to choose a new password:\n\n%s\n\n"+
+			"%s\n",
+			user.Username, reason.intro, cfg.PasswordResetTTL, link, reason.outro),
+	})
+}
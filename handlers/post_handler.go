@@ -1,14 +1,35 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/Jason-cqtan/simple-blog/auth"
+	"github.com/Jason-cqtan/simple-blog/markdown"
 	"github.com/Jason-cqtan/simple-blog/models"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
+// Posts per page on the home page and the post list, which accepts up to
+// maxPostsPerPage through ?per_page=.
+const (
+	postsPerPage    = 10
+	maxPostsPerPage = 50
+)
+
+var (
+	errUnknownPostAction = errors.New("unknown action")
+	errPublishAtPast     = errors.New("publish time must be in the future")
+	errUnknownCategory   = errors.New("unknown category")
+)
+
 type PostHandler struct {
 	db *gorm.DB
 }
@@ -17,49 +38,134 @@ func NewPostHandler(db *gorm.DB) *PostHandler {
 	return &PostHandler{db: db}
 }
 
+// Home shows published posts, newest first, one page at a time.
 func (h *PostHandler) Home(c *gin.Context) {
+	var total int64
+	h.db.Model(&models.Post{}).Where("published = ?", true).Count(&total)
+	pagination := newPagination(c, total, postsPerPage, maxPostsPerPage)
+
 	var posts []models.Post
-	h.db.Preload("Author").Where("published = ?", true).Order("created_at desc").Limit(10).Find(&posts)
-	c.HTML(http.StatusOK, "home.html", gin.H{
-		"title": "Home",
-		"posts": posts,
+	pagination.Scope(h.db.Preload("Author").Where("published = ?", true).Order("published_at desc")).Find(&posts)
+	renderHTML(c, http.StatusOK, "home.html", gin.H{
+		"title":      "Home",
+		"posts":      posts,
+		"pagination": pagination,
 	})
 }
 
+// List shows published posts, newest first, one page at a time.
 func (h *PostHandler) List(c *gin.Context) {
+	var total int64
+	h.db.Model(&models.Post{}).Where("published = ?", true).Count(&total)
+	pagination := newPagination(c, total, postsPerPage, maxPostsPerPage)
+
 	var posts []models.Post
-	h.db.Preload("Author").Where("published = ?", true).Order("created_at desc").Find(&posts)
-	c.HTML(http.StatusOK, "posts/list.html", gin.H{
-		"title": "All Posts",
-		"posts": posts,
+	pagination.Scope(h.db.Preload("Author").Preload("Category").Preload("Tags", models.OrderTags).Where("published = ?", true).Order("published_at desc")).Find(&posts)
+	renderHTML(c, http.StatusOK, "posts/list.html", gin.H{
+		"title":      "All Posts",
+		"posts":      posts,
+		"pagination": pagination,
 	})
 }
 
 func (h *PostHandler) Show(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.HTML(http.StatusBadRequest, "posts/detail.html", gin.H{"error": "Invalid post ID"})
+		renderHTML(c, http.StatusBadRequest, "posts/detail.html", gin.H{"error": "Invalid post ID"})
+		return
+	}
+
+	var post models.Post
+	if err := h.db.Preload("Author").First(&post, id).Error; err != nil || !post.Published {
+		renderHTML(c, http.StatusNotFound, "posts/detail.html", gin.H{"error": "Post not found"})
+		return
+	}
+	h.show(c, &post, false)
+}
+
+// ShowBySlug serves a post at its permalink, /:year/:month/:slug. Links
+// with an earlier slug or the wrong date are redirected permanently to the
+// current permalink.
+func (h *PostHandler) ShowBySlug(c *gin.Context) {
+	var post models.Post
+	err := h.db.Preload("Author").Where("slug = ?", c.Param("slug")).First(&post).Error
+	if err != nil {
+		var old models.PostSlug
+		if h.db.Where("slug = ?", c.Param("slug")).First(&old).Error != nil || h.db.First(&post, old.PostID).Error != nil {
+			renderHTML(c, http.StatusNotFound, "posts/detail.html", gin.H{"error": "Post not found"})
+			return
+		}
+	}
+	if !post.Published {
+		renderHTML(c, http.StatusNotFound, "posts/detail.html", gin.H{"error": "Post not found"})
+		return
+	}
+
+	if c.Request.URL.Path != post.URL() {
+		c.Redirect(http.StatusMovedPermanently, post.URL())
+		return
+	}
+	h.show(c, &post, false)
+}
+
+// Preview shows a post in any state to those who may edit it, so drafts and
+// scheduled posts can be read before they go live.
+func (h *PostHandler) Preview(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		renderHTML(c, http.StatusBadRequest, "posts/detail.html", gin.H{"error": "Invalid post ID"})
 		return
 	}
 
 	var post models.Post
-	if err := h.db.Preload("Author").First(&post, id).Error; err != nil {
-		c.HTML(http.StatusNotFound, "posts/detail.html", gin.H{"error": "Post not found"})
+	if err := h.db.Preload("Author").First(&post, id).Error; err != nil ||
+		!auth.CanModify(c, post.AuthorID, models.PermPostEditOwn, models.PermPostEditAny) {
+		renderHTML(c, http.StatusNotFound, "posts/detail.html", gin.H{"error": "Post not found"})
 		return
 	}
+	h.show(c, &post, true)
+}
+
+func (h *PostHandler) show(c *gin.Context, post *models.Post, preview bool) {
+	h.db.Model(post).Order("tags.slug").Association("Tags").Find(&post.Tags)
+
+	var breadcrumbs []models.Category
+	if post.CategoryID != nil {
+		path, err := models.CategoryPath(h.db, *post.CategoryID)
+		if err == nil && len(path) > 0 {
+			breadcrumbs = path
+			post.Category = &path[len(path)-1]
+		}
+	}
 
 	var comments []models.Comment
-	h.db.Preload("Author").Where("post_id = ?", id).Find(&comments)
+	h.db.Preload("Author").Where("post_id = ?", post.ID).Find(&comments)
 
-	c.HTML(http.StatusOK, "posts/detail.html", gin.H{
-		"title":    post.Title,
-		"post":     post,
-		"comments": comments,
+	renderHTML(c, http.StatusOK, "posts/detail.html", gin.H{
+		"title":       post.Title,
+		"post":        post,
+		"content":     postContentHTML(h.db, post),
+		"comments":    comments,
+		"preview":     preview,
+		"breadcrumbs": breadcrumbs,
 	})
 }
 
 func (h *PostHandler) ShowCreateForm(c *gin.Context) {
-	c.HTML(http.StatusOK, "posts/create.html", gin.H{"title": "Create Post"})
+	h.renderCreateForm(c, http.StatusOK, gin.H{})
+}
+
+// renderCreateForm renders the new post form with the category choices.
+func (h *PostHandler) renderCreateForm(c *gin.Context, status int, extra gin.H) {
+	categories, _ := models.CategoryTree(h.db)
+	data := gin.H{
+		"title":      "Create Post",
+		"categories": categories,
+	}
+	for k, v := range extra {
+		data[k] = v
+	}
+	renderHTML(c, status, "posts/create.html", data)
 }
 
 func (h *PostHandler) Create(c *gin.Context) {
@@ -68,58 +174,86 @@ func (h *PostHandler) Create(c *gin.Context) {
 	title := c.PostForm("title")
 	content := c.PostForm("content")
 	excerpt := c.PostForm("excerpt")
-	category := c.PostForm("category")
-	tags := c.PostForm("tags")
 
 	if title == "" {
-		c.HTML(http.StatusBadRequest, "posts/create.html", gin.H{"error": "Title is required"})
+		h.renderCreateForm(c, http.StatusBadRequest, gin.H{"error": "Title is required"})
+		return
+	}
+	status, publishAt, err := postStatusFromForm(c, models.PostStatusPublished)
+	if err == nil && status == models.PostStatusArchived {
+		err = errUnknownPostAction
+	}
+	if err != nil {
+		h.renderCreateForm(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	categoryID, err := postCategoryFromForm(h.db, c)
+	if err != nil {
+		h.renderCreateForm(c, http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
 	post := models.Post{
-		Title:     title,
-		Content:   content,
-		Excerpt:   excerpt,
-		Category:  category,
-		Tags:      tags,
-		AuthorID:  userID.(uint),
-		Published: true,
+		Title:      title,
+		Content:    content,
+		Excerpt:    excerpt,
+		CategoryID: categoryID,
+		AuthorID:   userID.(uint),
 	}
+	post.SetStatus(status, publishAt, time.Now())
 
-	if err := h.db.Create(&post).Error; err != nil {
-		c.HTML(http.StatusInternalServerError, "posts/create.html", gin.H{"error": "Failed to create post: " + err.Error()})
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		tags, err := models.FindOrCreateTags(tx, c.PostForm("tags"))
+		if err != nil {
+			return err
+		}
+		post.Tags = tags
+		return tx.Create(&post).Error
+	})
+	if errors.Is(err, models.ErrTagNameTooLong) {
+		h.renderCreateForm(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.renderCreateForm(c, http.StatusInternalServerError, gin.H{"error": "Failed to create post: " + err.Error()})
 		return
 	}
 
-	c.Redirect(http.StatusFound, "/posts/"+strconv.Itoa(int(post.ID)))
+	c.Redirect(http.StatusFound, postLocation(&post))
 }
 
 func (h *PostHandler) ShowEditForm(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.HTML(http.StatusBadRequest, "posts/edit.html", gin.H{"error": "Invalid post ID"})
+		renderHTML(c, http.StatusBadRequest, "posts/edit.html", gin.H{"error": "Invalid post ID"})
 		return
 	}
 
 	var post models.Post
-	if err := h.db.First(&post, id).Error; err != nil {
-		c.HTML(http.StatusNotFound, "posts/edit.html", gin.H{"error": "Post not found"})
+	if err := h.db.Preload("Tags", models.OrderTags).First(&post, id).Error; err != nil {
+		renderHTML(c, http.StatusNotFound, "posts/edit.html", gin.H{"error": "Post not found"})
 		return
 	}
 
-	userID, _ := c.Get("userID")
-	if post.AuthorID != userID.(uint) {
-		c.HTML(http.StatusForbidden, "posts/edit.html", gin.H{"error": "Forbidden"})
+	if !auth.CanModify(c, post.AuthorID, models.PermPostEditOwn, models.PermPostEditAny) {
+		renderHTML(c, http.StatusForbidden, "posts/edit.html", gin.H{"error": "Forbidden"})
 		return
 	}
 
-	c.HTML(http.StatusOK, "posts/edit.html", gin.H{
-		"title": "Edit Post",
-		"post":  post,
-	})
+	categories, _ := models.CategoryTree(h.db)
+	data := gin.H{
+		"title":      "Edit Post",
+		"post":       &post,
+		"categories": categories,
+	}
+	if post.Status == models.PostStatusScheduled && post.PublishAt != nil {
+		data["publish_at"] = post.PublishAt.In(time.Local).Format(datetimeLocalLayout)
+	}
+	renderHTML(c, http.StatusOK, "posts/edit.html", data)
 }
 
 func (h *PostHandler) Update(c *gin.Context) {
+	userID, _ := c.Get("userID")
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post ID"})
@@ -132,24 +266,63 @@ func (h *PostHandler) Update(c *gin.Context) {
 		return
 	}
 
-	userID, _ := c.Get("userID")
-	if post.AuthorID != userID.(uint) {
+	if !auth.CanModify(c, post.AuthorID, models.PermPostEditOwn, models.PermPostEditAny) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
 		return
 	}
 
+	// Without an action button (e.g. API clients) the status is unchanged.
+	status, publishAt, err := postStatusFromForm(c, post.Status)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if c.PostForm("action") == "" {
+		publishAt = post.PublishAt
+	}
+	post.SetStatus(status, publishAt, time.Now())
+
 	post.Title = c.PostForm("title")
 	post.Content = c.PostForm("content")
 	post.Excerpt = c.PostForm("excerpt")
-	post.Category = c.PostForm("category")
-	post.Tags = c.PostForm("tags")
+	post.CategoryID, err = postCategoryFromForm(h.db, c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// An emptied slug field makes a new slug from the title. The old slug
+	// stays in the post's history and redirects here.
+	wantSlug := strings.TrimSpace(c.PostForm("slug"))
+	if wantSlug == "" {
+		wantSlug = post.Title
+	}
+	if models.Slugify(wantSlug) != post.Slug {
+		slug, err := models.UniquePostSlug(h.db, post.ID, wantSlug)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update post: " + err.Error()})
+			return
+		}
+		post.Slug = slug
+	}
 
-	if err := h.db.Save(&post).Error; err != nil {
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		tags, err := models.FindOrCreateTags(tx, c.PostForm("tags"))
+		if err != nil {
+			return err
+		}
+		return savePost(tx, &post, tags, userID.(uint), "")
+	})
+	if errors.Is(err, models.ErrTagNameTooLong) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update post: " + err.Error()})
 		return
 	}
 
-	c.Redirect(http.StatusFound, "/posts/"+strconv.Itoa(id))
+	c.Redirect(http.StatusFound, postLocation(&post))
 }
 
 func (h *PostHandler) Delete(c *gin.Context) {
@@ -165,15 +338,110 @@ func (h *PostHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	userID, _ := c.Get("userID")
-	if post.AuthorID != userID.(uint) {
+	if !auth.CanModify(c, post.AuthorID, models.PermPostDeleteOwn, models.PermPostDeleteAny) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
 		return
 	}
 
-	if err := h.db.Delete(&models.Post{}, id).Error; err != nil {
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("post_id = ?", id).Delete(&models.PostRevision{}).Error; err != nil {
+			return err
+		}
+		// Frees the post's current and earlier slugs for other posts.
+		if err := tx.Where("post_id = ?", id).Delete(&models.PostSlug{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&post).Association("Tags").Clear(); err != nil {
+			return err
+		}
+		return tx.Delete(&models.Post{}, id).Error
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete post: " + err.Error()})
 		return
 	}
 	c.Redirect(http.StatusFound, "/posts")
 }
+
+// savePost saves an edited post with tags and records the change as a
+// revision by editorID.
+func savePost(tx *gorm.DB, post *models.Post, tags []models.Tag, editorID uint, note string) error {
+	if err := tx.Omit("Tags").Save(post).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(post).Association("Tags").Replace(tags); err != nil {
+		return err
+	}
+	post.Tags = tags
+	return models.RecordPostRevision(tx, post, editorID, note)
+}
+
+// postCategoryFromForm reads the category_id field; empty means no category.
+func postCategoryFromForm(db *gorm.DB, c *gin.Context) (*uint, error) {
+	value := c.PostForm("category_id")
+	if value == "" {
+		return nil, nil
+	}
+	id, err := strconv.Atoi(value)
+	if err != nil || db.First(&models.Category{}, id).Error != nil {
+		return nil, errUnknownCategory
+	}
+	category := uint(id)
+	return &category, nil
+}
+
+// postStatusFromForm reads the editor's action button ("draft", "schedule",
+// "publish" or "archive") and, for scheduling, the publish_at field. Without
+// an action the post keeps status current.
+func postStatusFromForm(c *gin.Context, current string) (string, *time.Time, error) {
+	switch c.PostForm("action") {
+	case "":
+		return current, nil, nil
+	case "draft":
+		return models.PostStatusDraft, nil, nil
+	case "publish":
+		return models.PostStatusPublished, nil, nil
+	case "archive":
+		return models.PostStatusArchived, nil, nil
+	case "schedule":
+		t, err := time.ParseInLocation(datetimeLocalLayout, c.PostForm("publish_at"), time.Local)
+		if err != nil || !t.After(time.Now()) {
+			return "", nil, errPublishAtPast
+		}
+		return models.PostStatusScheduled, &t, nil
+	}
+	return "", nil, errUnknownPostAction
+}
+
+// postLocation is where to send the author after saving: the permalink once
+// the post is live, its preview until then.
+func postLocation(post *models.Post) string {
+	if post.Published {
+		return post.URL()
+	}
+	return fmt.Sprintf("/posts/%d/preview", post.ID)
+}
+
+// postContentHTML returns the post's content rendered from Markdown. The
+// HTML is cached on the post and only rendered again once the content (or
+// the renderer's markdown.Version) changes.
+func postContentHTML(db *gorm.DB, post *models.Post) template.HTML {
+	key := markdown.Key(post.Content)
+	if post.ContentHTMLKey == key {
+		return template.HTML(post.ContentHTML)
+	}
+
+	rendered, err := markdown.Render(post.Content)
+	if err != nil {
+		log.Printf("render post %d: %v", post.ID, err)
+		return template.HTML("<p>" + template.HTMLEscapeString(post.Content) + "</p>")
+	}
+	if err := db.Model(&models.Post{}).Where("id = ?", post.ID).UpdateColumns(map[string]interface{}{
+		"content_html":     string(rendered),
+		"content_html_key": key,
+	}).Error; err != nil {
+		log.Printf("cache rendered post %d: %v", post.ID, err)
+	}
+	post.ContentHTML, post.ContentHTMLKey = string(rendered), key
+	return rendered
+}
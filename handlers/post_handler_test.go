@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Jason-cqtan/simple-blog/markdown"
+	"github.com/Jason-cqtan/simple-blog/models"
+	"gorm.io/gorm"
+)
+
+func TestPostContentHTML(t *testing.T) {
+	db := openTestDB(t)
+	author := models.User{Username: "fay", Email: "fay@example.com", Password: "x"}
+	if err := db.Create(&author).Error; err != nil {
+		t.Fatal(err)
+	}
+	long := strings.Repeat("Some **bold** words and a [link](https://example.com).\n\n", 2000)
+	post := models.Post{Title: "Long", Content: long, AuthorID: author.ID}
+	if err := db.Create(&post).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	html := string(postContentHTML(db, &post))
+	if len(html) < 64<<10 || !strings.Contains(html, "<strong>bold</strong>") {
+		t.Fatalf("rendered %d bytes, want more than 64 KB of HTML", len(html))
+	}
+	var cached models.Post
+	db.First(&cached, post.ID)
+	if cached.ContentHTMLKey != markdown.Key(long) || cached.ContentHTML != html {
+		t.Errorf("cache not written: key %q, %d bytes", cached.ContentHTMLKey, len(cached.ContentHTML))
+	}
+
+	// A current key serves the cached HTML without rendering again.
+	cached.ContentHTML = "<p>from cache</p>"
+	if got := postContentHTML(db, &cached); got != "<p>from cache</p>" {
+		t.Errorf("cached post rendered again: %q", got)
+	}
+}
+
+func TestPostContentHTMLWithoutCache(t *testing.T) {
+	db := openTestDB(t)
+	author := models.User{Username: "gus", Email: "gus@example.com", Password: "x"}
+	if err := db.Create(&author).Error; err != nil {
+		t.Fatal(err)
+	}
+	post := models.Post{Title: "Hi", Content: "*hi*", AuthorID: author.ID}
+	if err := db.Create(&post).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// Every update fails from here on.
+	if err := db.Callback().Update().Before("gorm:update").Register("test:fail", func(tx *gorm.DB) {
+		_ = tx.AddError(errors.New("disk full"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := postContentHTML(db, &post); !strings.Contains(string(got), "<em>hi</em>") {
+		t.Errorf("postContentHTML = %q, want the rendered HTML", got)
+	}
+	var stored models.Post
+	db.First(&stored, post.ID)
+	if stored.ContentHTMLKey != "" {
+		t.Errorf("cache key = %q, want the failed write to leave it empty", stored.ContentHTMLKey)
+	}
+}
+
+func TestShowBySlugRedirects(t *testing.T) {
+	db := openTestDB(t)
+	author := models.User{Username: "hal", Email: "hal@example.com", Password: "x"}
+	if err := db.Create(&author).Error; err != nil {
+		t.Fatal(err)
+	}
+	published := time.Date(2024, 3, 9, 12, 0, 0, 0, time.UTC)
+	post := models.Post{Title: "First Title", Content: "x", AuthorID: author.ID}
+	post.SetStatus(models.PostStatusPublished, nil, published)
+	if err := db.Create(&post).Error; err != nil {
+		t.Fatal(err)
+	}
+	draft := models.Post{Title: "Draft", Content: "x", AuthorID: author.ID}
+	if err := db.Create(&draft).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	post.Slug = "second-title"
+	if err := savePost(db, &post, nil, author.ID, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	router := newTestRouter(t)
+	router.GET("/:year/:month/:slug", NewPostHandler(db).ShowBySlug)
+
+	tests := []struct {
+		path     string
+		want     int
+		location string
+	}{
+		{path: "/2024/03/second-title", want: http.StatusOK},
+		{path: "/2024/03/first-title", want: http.StatusMovedPermanently, location: "/2024/03/second-title"},
+		{path: "/2019/01/first-title", want: http.StatusMovedPermanently, location: "/2024/03/second-title"},
+		{path: "/2024/04/second-title", want: http.StatusMovedPermanently, location: "/2024/03/second-title"},
+		{path: "/2024/03/unknown", want: http.StatusNotFound},
+		{path: "/2024/03/draft", want: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != tt.want || rec.Header().Get("Location") != tt.location {
+			t.Errorf("GET %s = %d %q, want %d %q", tt.path, rec.Code, rec.Header().Get("Location"), tt.want, tt.location)
+		}
+	}
+}
+
+func TestPostDeleteFreesSlugs(t *testing.T) {
+	db := openTestDB(t)
+	author := models.User{Username: "hal", Email: "hal@example.com", Password: "x"}
+	if err := db.Create(&author).Error; err != nil {
+		t.Fatal(err)
+	}
+	post := models.Post{Title: "First Title", Content: "x", AuthorID: author.ID, Published: true}
+	if err := db.Create(&post).Error; err != nil {
+		t.Fatal(err)
+	}
+	post.Slug = "second-title"
+	if err := savePost(db, &post, nil, author.ID, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	router := newTestRouter(t)
+	router.POST("/posts/:id/delete", signInAs(t, db, author.ID), NewPostHandler(db).Delete)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, fmt.Sprintf("/posts/%d/delete", post.ID), nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("status = %d, want 302: %s", rec.Code, rec.Body)
+	}
+
+	var slugs int64
+	db.Model(&models.PostSlug{}).Where("post_id = ?", post.ID).Count(&slugs)
+	if slugs != 0 {
+		t.Errorf("%d slugs left for the deleted post", slugs)
+	}
+	for _, title := range []string{"First Title", "Second Title"} {
+		slug, err := models.UniquePostSlug(db, 0, title)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := models.Slugify(title); slug != want {
+			t.Errorf("UniquePostSlug(%q) = %q, want %q", title, slug, want)
+		}
+	}
+}
+
+func TestHomePagination(t *testing.T) {
+	db := openTestDB(t)
+	author := models.User{Username: "hal", Email: "hal@example.com", Password: "x"}
+	if err := db.Create(&author).Error; err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 1; i <= 12; i++ {
+		post := models.Post{Title: fmt.Sprintf("Post %02d", i), Content: "x", AuthorID: author.ID}
+		post.SetStatus(models.PostStatusPublished, nil, start.AddDate(0, 0, i))
+		if err := db.Create(&post).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	router := newTestRouter(t)
+	router.GET("/", NewPostHandler(db).Home)
+
+	tests := []struct {
+		query string
+		want  []string // newest first
+		page  string
+	}{
+		{query: "", want: []string{"Post 12", "Post 03"}, page: "Page 1 of 2, 12 in all"},
+		{query: "?page=2", want: []string{"Post 02", "Post 01"}, page: "Page 2 of 2, 12 in all"},
+		{query: "?page=2&per_page=5", want: []string{"Post 07", "Post 03"}, page: "Page 2 of 3, 12 in all"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/"+tt.query, nil))
+		body := rec.Body.String()
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET /%s: status %d", tt.query, rec.Code)
+		}
+		first, last := strings.Index(body, tt.want[0]), strings.Index(body, tt.want[1])
+		if first < 0 || last < first {
+			t.Errorf("GET /%s does not list %s through %s", tt.query, tt.want[0], tt.want[1])
+		}
+		if !strings.Contains(body, tt.page) {
+			t.Errorf("GET /%s lacks %q", tt.query, tt.page)
+		}
+	}
+
+	// Posts outside the page are left out.
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?page=2&per_page=5", nil))
+	for _, title := range []string{"Post 08", "Post 02"} {
+		if strings.Contains(rec.Body.String(), title) {
+			t.Errorf("page 2 of 5 lists %s", title)
+		}
+	}
+}
@@ -0,0 +1,321 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Jason-cqtan/simple-blog/auth"
+	"github.com/Jason-cqtan/simple-blog/config"
+	"github.com/Jason-cqtan/simple-blog/mailer"
+	"github.com/Jason-cqtan/simple-blog/media"
+	"github.com/Jason-cqtan/simple-blog/models"
+	"github.com/Jason-cqtan/simple-blog/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ProfileHandler lets signed-in users edit their own account.
+type ProfileHandler struct {
+	db   *gorm.DB
+	cfg  *config.Config
+	mail mailer.Mailer
+}
+
+func NewProfileHandler(db *gorm.DB, cfg *config.Config, mail mailer.Mailer) *ProfileHandler {
+	return &ProfileHandler{db: db, cfg: cfg, mail: mail}
+}
+
+func (h *ProfileHandler) ShowEditForm(c *gin.Context) {
+	h.render(c, http.StatusOK, gin.H{})
+}
+
+// Update saves the public profile fields and username. A new email address
+// needs the current password (or authentication code, for accounts without
+// one) and only replaces the old one once the link sent to it is followed.
+func (h *ProfileHandler) Update(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	username := strings.TrimSpace(c.PostForm("username"))
+	email := strings.TrimSpace(c.PostForm("email"))
+	displayName := strings.TrimSpace(c.PostForm("display_name"))
+	website := strings.TrimSpace(c.PostForm("website"))
+	bio := strings.TrimSpace(c.PostForm("bio"))
+
+	for _, err := range []error{
+		utils.ValidateUsername(username),
+		utils.ValidateMaxLength(username, "username", 100),
+		utils.ValidateEmail(email),
+		utils.ValidateMaxLength(displayName, "display name", 100),
+		utils.ValidateWebsite(website),
+		utils.ValidateMaxLength(bio, "bio", 500),
+	} {
+		if err != nil {
+			h.render(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if username != user.Username && (models.IsReservedUsername(username) || h.taken("username", username, user.ID)) {
+		h.render(c, http.StatusBadRequest, gin.H{"error": "Username is already taken"})
+		return
+	}
+	emailChanged := !strings.EqualFold(email, user.Email)
+	if emailChanged && h.taken("email", email, user.ID) {
+		h.render(c, http.StatusBadRequest, gin.H{"error": "Email is already in use"})
+		return
+	}
+	if emailChanged && !reauthenticate(h.db, user, c.PostForm("current_password"), c.PostForm("code")) {
+		msg := "Enter your current password to change your email address"
+		if !user.HasPassword() {
+			msg = "Enter an authentication code to change your email address"
+			if !user.TOTPEnabled {
+				msg = "Set a password with a password reset link before changing your email address"
+			}
+		}
+		h.render(c, http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	user.Username = username
+	user.DisplayName = displayName
+	user.Website = website
+	user.Bio = bio
+	if err := h.db.Model(user).
+		Select("Username", "DisplayName", "Website", "Bio").
+		Updates(user).Error; err != nil {
+		h.render(c, http.StatusBadRequest, gin.H{"error": "Username already exists"})
+		return
+	}
+
+	message := "Your profile has been updated."
+	if emailChanged {
+		if err := h.sendEmailChangeLink(user, email); err != nil {
+			log.Printf("email change link for user %d: %v", user.ID, err)
+			h.render(c, http.StatusInternalServerError, gin.H{"error": "Your profile has been updated, but the confirmation link for your new email address could not be sent"})
+			return
+		}
+		message += " Your email address changes to " + email + " once you follow the link we sent there."
+	}
+	h.render(c, http.StatusOK, gin.H{"message": message})
+}
+
+// ConfirmEmail applies an email change requested in Update. Following the
+// link proves the new address, so it is verified at once, and the previous
+// address is told about the change.
+func (h *ProfileHandler) ConfirmEmail(c *gin.Context) {
+	data := gin.H{"title": "Confirm Email Change"}
+	fail := func(status int, msg string) {
+		data["error"] = msg
+		renderHTML(c, status, "users/verify_email.html", data)
+	}
+
+	claims, err := utils.ParseEmailChangeToken(c.Query("token"), h.cfg.Keyring)
+	if err != nil {
+		fail(http.StatusBadRequest, "Invalid or expired confirmation link")
+		return
+	}
+	if h.taken("email", claims.NewEmail, claims.UserID) {
+		fail(http.StatusBadRequest, "Email is already in use")
+		return
+	}
+
+	// Matching on the old address makes the link single-use.
+	result := h.db.Model(&models.User{}).
+		Where("id = ? AND email = ?", claims.UserID, claims.Email).
+		Updates(map[string]interface{}{"email": claims.NewEmail, "email_verified_at": time.Now()})
+	if result.Error != nil {
+		log.Printf("email change for user %d: %v", claims.UserID, result.Error)
+		fail(http.StatusInternalServerError, "Failed to change email address")
+		return
+	}
+	if result.RowsAffected == 0 {
+		fail(http.StatusBadRequest, "Invalid or expired confirmation link")
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, claims.UserID).Error; err == nil {
+		if err := h.notifyEmailChange(&user, claims.Email); err != nil {
+			log.Printf("email change notice for user %d: %v", user.ID, err)
+		}
+	}
+	data["message"] = "Your email address has been changed to " + claims.NewEmail + "."
+	renderHTML(c, http.StatusOK, "users/verify_email.html", data)
+}
+
+// ChangePassword replaces the password after checking the current one, and
+// signs out every other session.
+func (h *ProfileHandler) ChangePassword(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	password := c.PostForm("password")
+	if !user.CheckPassword(c.PostForm("current_password")) {
+		h.render(c, http.StatusBadRequest, gin.H{"password_error": "Current password is incorrect"})
+		return
+	}
+	if password != c.PostForm("password_confirm") {
+		h.render(c, http.StatusBadRequest, gin.H{"password_error": "Passwords do not match"})
+		return
+	}
+	if err := h.cfg.PasswordPolicy.Validate(password, user.Username, user.Email); err != nil {
+		h.render(c, http.StatusBadRequest, gin.H{"password_error": err.Error()})
+		return
+	}
+	if err := user.HashPassword(h.cfg.PasswordHasher, password); err != nil {
+		h.render(c, http.StatusInternalServerError, gin.H{"password_error": "Failed to process password"})
+		return
+	}
+
+	sessionID, _ := c.Get("sessionID")
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Update("password", user.Password).Error; err != nil {
+			return err
+		}
+		return auth.RevokeOtherSessions(tx, user.ID, sessionID.(uint))
+	})
+	if err != nil {
+		h.render(c, http.StatusInternalServerError, gin.H{"password_error": "Failed to change password"})
+		return
+	}
+	h.render(c, http.StatusOK, gin.H{"password_message": "Your password has been changed. Other devices have been signed out."})
+}
+
+// UploadAvatar stores a resized copy of the uploaded image and replaces the
+// previous avatar.
+func (h *ProfileHandler) UploadAvatar(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	maxBytes := int64(h.cfg.AvatarMaxBytes)
+	// Leave room for the rest of the multipart body around the file.
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes+64<<10)
+	header, err := c.FormFile("avatar")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.render(c, http.StatusRequestEntityTooLarge, gin.H{"avatar_error": fmt.Sprintf("Image must be at most %d KB", maxBytes>>10)})
+			return
+		}
+		h.render(c, http.StatusBadRequest, gin.H{"avatar_error": "Choose an image to upload"})
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		h.render(c, http.StatusBadRequest, gin.H{"avatar_error": "Failed to read upload"})
+		return
+	}
+	defer func() { _ = file.Close() }()
+
+	name, err := media.SaveAvatar(h.cfg.MediaDir, file, maxBytes, h.cfg.AvatarSize)
+	switch {
+	case errors.Is(err, media.ErrTooLarge):
+		h.render(c, http.StatusRequestEntityTooLarge, gin.H{"avatar_error": fmt.Sprintf("Image must be at most %d KB", maxBytes>>10)})
+		return
+	case errors.Is(err, media.ErrUnsupportedImage), errors.Is(err, media.ErrImageDimensions):
+		h.render(c, http.StatusBadRequest, gin.H{"avatar_error": err.Error()})
+		return
+	case err != nil:
+		log.Printf("avatar upload for user %d: %v", user.ID, err)
+		h.render(c, http.StatusInternalServerError, gin.H{"avatar_error": "Failed to save image"})
+		return
+	}
+
+	h.replaceAvatar(c, user, name)
+}
+
+func (h *ProfileHandler) RemoveAvatar(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+	h.replaceAvatar(c, user, "")
+}
+
+func (h *ProfileHandler) replaceAvatar(c *gin.Context, user *models.User, name string) {
+	old := user.Avatar
+	if err := h.db.Model(user).Update("avatar", name).Error; err != nil {
+		_ = media.Remove(h.cfg.MediaDir, name)
+		h.render(c, http.StatusInternalServerError, gin.H{"avatar_error": "Failed to update avatar"})
+		return
+	}
+	if err := media.Remove(h.cfg.MediaDir, old); err != nil {
+		log.Printf("remove avatar %s: %v", old, err)
+	}
+	c.Redirect(http.StatusFound, "/profile/edit")
+}
+
+func (h *ProfileHandler) currentUser(c *gin.Context) (*models.User, bool) {
+	userID, _ := c.Get("userID")
+
+	var user models.User
+	if err := h.db.First(&user, userID).Error; err != nil {
+		renderHTML(c, http.StatusNotFound, "users/edit_profile.html", gin.H{"title": "Edit Profile", "error": "User not found"})
+		return nil, false
+	}
+	return &user, true
+}
+
+// taken reports whether another user already has value in column.
+func (h *ProfileHandler) taken(column, value string, userID uint) bool {
+	var count int64
+	h.db.Model(&models.User{}).Where("LOWER("+column+") = LOWER(?) AND id <> ?", value, userID).Count(&count)
+	return count > 0
+}
+
+// sendEmailChangeLink mails newEmail the link that confirms the change.
+func (h *ProfileHandler) sendEmailChangeLink(user *models.User, newEmail string) error {
+	token, err := utils.GenerateEmailChangeToken(user.ID, user.Email, newEmail, h.cfg.Keyring, h.cfg.EmailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	link := h.cfg.BaseURL + "/profile/email/confirm?token=" + url.QueryEscape(token)
+	return h.mail.Send(mailer.Message{
+		To:      newEmail,
+		Subject: "Confirm your new Simple Blog email address",
+		Body: fmt.Sprintf("Hi %s,\n\nTo use this address for your account, open the link below within %s:\n\n%s\n\n"+
+			"If you did not ask for this change, you can ignore this email.\n",
+			user.Username, h.cfg.EmailVerificationTTL, link),
+	})
+}
+
+// notifyEmailChange tells the previous address that it no longer receives
+// mail for the account, so an unexpected change does not go unnoticed.
+func (h *ProfileHandler) notifyEmailChange(user *models.User, oldEmail string) error {
+	return h.mail.Send(mailer.Message{
+		To:      oldEmail,
+		Subject: "Your Simple Blog email address was changed",
+		Body: fmt.Sprintf("Hi %s,\n\nThe email address of your account was changed to %s.\n\n"+
+			"If you did not make this change, reset your password and contact an administrator.\n",
+			user.Username, user.Email),
+	})
+}
+
+func (h *ProfileHandler) render(c *gin.Context, status int, extra gin.H) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	data := gin.H{
+		"title": "Edit Profile",
+		"user":  user,
+	}
+	for k, v := range extra {
+		data[k] = v
+	}
+	renderHTML(c, status, "users/edit_profile.html", data)
+}
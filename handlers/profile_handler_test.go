@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/Jason-cqtan/simple-blog/mailer"
+	"github.com/Jason-cqtan/simple-blog/models"
+	"github.com/gin-gonic/gin"
+)
+
+var tokenInLink = regexp.MustCompile(`token=([A-Za-z0-9_\-%.]+)`)
+
+func TestProfileEmailChange(t *testing.T) {
+	db := openTestDB(t)
+	cfg := newTestConfig(t)
+	mail := mailer.NewMemoryMailer()
+	h := NewProfileHandler(db, cfg, mail)
+
+	withPassword := models.User{Username: "carol", Email: "carol@example.com"}
+	if err := withPassword.HashPassword(cfg.PasswordHasher, "wintergreen7"); err != nil {
+		t.Fatal(err)
+	}
+	passwordless := models.User{Username: "sso", Email: "sso@example.com"}
+	for _, u := range []*models.User{&withPassword, &passwordless} {
+		if err := db.Create(u).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	router := newTestRouter(t)
+	signedIn := router.Group("/", func(c *gin.Context) {
+		id := withPassword.ID
+		if c.GetHeader("X-Test-User") == "sso" {
+			id = passwordless.ID
+		}
+		c.Set("userID", id)
+	})
+	signedIn.POST("/profile", h.Update)
+	router.GET("/profile/email/confirm", h.ConfirmEmail)
+
+	update := func(user string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/profile", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("X-Test-User", user)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	confirm := func(token string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/profile/email/confirm?token="+token, nil))
+		return rec
+	}
+	reload := func(id uint) models.User {
+		var u models.User
+		db.First(&u, id)
+		return u
+	}
+
+	form := url.Values{"username": {"carol"}, "email": {"new@example.com"}, "display_name": {"Carol"}}
+	for _, password := range []string{"", "wrong"} {
+		form.Set("current_password", password)
+		if rec := update("carol", form); rec.Code != http.StatusBadRequest {
+			t.Errorf("password %q: status %d, want 400", password, rec.Code)
+		}
+	}
+	if rec := update("sso", url.Values{"username": {"sso"}, "email": {"new@example.com"}}); rec.Code != http.StatusBadRequest ||
+		!strings.Contains(rec.Body.String(), "password reset link") {
+		t.Errorf("account without password or 2FA: status %d, want 400 asking for a password", rec.Code)
+	}
+	if n := len(mail.Messages()); n != 0 {
+		t.Fatalf("%d mails sent for refused changes", n)
+	}
+
+	form.Set("current_password", "wintergreen7")
+	if rec := update("carol", form); rec.Code != http.StatusOK {
+		t.Fatalf("update: status %d\n%s", rec.Code, rec.Body.String())
+	}
+	if u := reload(withPassword.ID); u.Email != "carol@example.com" || u.DisplayName != "Carol" {
+		t.Errorf("before confirming: email %q, display name %q", u.Email, u.DisplayName)
+	}
+	msgs := mail.Messages()
+	if len(msgs) != 1 || msgs[0].To != "new@example.com" {
+		t.Fatalf("mails = %+v, want one to the new address", msgs)
+	}
+	token := tokenInLink.FindStringSubmatch(msgs[0].Body)[1]
+
+	if rec := confirm("garbage"); rec.Code != http.StatusBadRequest {
+		t.Errorf("bad token: status %d, want 400", rec.Code)
+	}
+	if rec := confirm(token); rec.Code != http.StatusOK {
+		t.Fatalf("confirm: status %d\n%s", rec.Code, rec.Body.String())
+	}
+	if u := reload(withPassword.ID); u.Email != "new@example.com" || !u.EmailVerified() {
+		t.Errorf("after confirming: email %q, verified %v", u.Email, u.EmailVerified())
+	}
+	if msgs := mail.Messages(); len(msgs) != 2 || msgs[1].To != "carol@example.com" {
+		t.Errorf("no notice to the old address: %+v", msgs)
+	}
+	if rec := confirm(token); rec.Code != http.StatusBadRequest {
+		t.Errorf("reused link: status %d, want 400", rec.Code)
+	}
+}
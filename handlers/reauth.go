@@ -0,0 +1,17 @@
+package handlers
+
+import (
+	"github.com/Jason-cqtan/simple-blog/auth"
+	"github.com/Jason-cqtan/simple-blog/models"
+	"gorm.io/gorm"
+)
+
+// reauthenticate confirms it is really the signed-in user asking for a
+// sensitive change: by their password or, for accounts without one, a
+// current authentication code. Accounts with neither cannot confirm.
+func reauthenticate(db *gorm.DB, user *models.User, password, code string) bool {
+	if user.HasPassword() {
+		return user.CheckPassword(password)
+	}
+	return user.TOTPEnabled && auth.VerifySecondFactor(db, user, code)
+}
@@ -0,0 +1,13 @@
+package handlers
+
+import (
+	"github.com/Jason-cqtan/simple-blog/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// renderHTML renders a template with the request's CSRF token added to
+// data, so every form can include it as {{ $.csrf_token }}.
+func renderHTML(c *gin.Context, status int, name string, data gin.H) {
+	data[middleware.CSRFField] = middleware.CSRFToken(c)
+	c.HTML(status, name, data)
+}
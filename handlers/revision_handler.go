@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/Jason-cqtan/simple-blog/auth"
+	"github.com/Jason-cqtan/simple-blog/diff"
+	"github.com/Jason-cqtan/simple-blog/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	revisionsPerPage    = 20
+	maxRevisionsPerPage = 100
+)
+
+// RevisionHandler lets those who may edit a post browse its revisions,
+// compare any two and restore an old one.
+type RevisionHandler struct {
+	db *gorm.DB
+}
+
+func NewRevisionHandler(db *gorm.DB) *RevisionHandler {
+	return &RevisionHandler{db: db}
+}
+
+// revisionFieldDiff is the diff of one post field between two revisions.
+type revisionFieldDiff struct {
+	Name string
+	Ops  []diff.Op
+}
+
+// List shows a post's revisions, newest first, with a form to compare two.
+func (h *RevisionHandler) List(c *gin.Context) {
+	post, ok := h.findPost(c, "posts/revisions.html")
+	if !ok {
+		return
+	}
+
+	var total int64
+	h.db.Model(&models.PostRevision{}).Where("post_id = ?", post.ID).Count(&total)
+	pagination := newPagination(c, total, revisionsPerPage, maxRevisionsPerPage)
+
+	var revisions []models.PostRevision
+	pagination.Scope(h.db.Preload("Editor").Where("post_id = ?", post.ID).Order("number desc")).Find(&revisions)
+
+	var latest int
+	if len(revisions) > 0 && pagination.Page == 1 {
+		latest = revisions[0].Number
+	}
+	renderHTML(c, http.StatusOK, "posts/revisions.html", gin.H{
+		"title":      "Revisions of " + post.Title,
+		"post":       post,
+		"revisions":  revisions,
+		"latest":     latest,
+		"pagination": pagination,
+	})
+}
+
+// Show shows the text of one revision.
+func (h *RevisionHandler) Show(c *gin.Context) {
+	post, ok := h.findPost(c, "posts/revision.html")
+	if !ok {
+		return
+	}
+	revision, ok := h.findRevision(post.ID, c.Param("number"))
+	if !ok {
+		renderHTML(c, http.StatusNotFound, "posts/revision.html", gin.H{"error": "Revision not found"})
+		return
+	}
+
+	renderHTML(c, http.StatusOK, "posts/revision.html", gin.H{
+		"title":    fmt.Sprintf("Revision %d of %s", revision.Number, post.Title),
+		"post":     post,
+		"revision": revision,
+	})
+}
+
+// Diff compares revisions ?from= and ?to=, by default the latest revision
+// and the one before it. ?mode=word diffs word by word instead of by line.
+func (h *RevisionHandler) Diff(c *gin.Context) {
+	post, ok := h.findPost(c, "posts/diff.html")
+	if !ok {
+		return
+	}
+
+	var latest models.PostRevision
+	if err := h.db.Where("post_id = ?", post.ID).Order("number desc").First(&latest).Error; err != nil {
+		renderHTML(c, http.StatusNotFound, "posts/diff.html", gin.H{"error": "Revision not found"})
+		return
+	}
+	toNumber := c.DefaultQuery("to", strconv.Itoa(latest.Number))
+	to, ok := h.findRevision(post.ID, toNumber)
+	if !ok {
+		renderHTML(c, http.StatusNotFound, "posts/diff.html", gin.H{"error": "Revision not found"})
+		return
+	}
+	// Revision 0 is the empty post before the first revision, so the first
+	// revision shows its whole text as added.
+	fromNumber := c.DefaultQuery("from", strconv.Itoa(to.Number-1))
+	from := &models.PostRevision{PostID: post.ID}
+	if fromNumber != "0" {
+		if from, ok = h.findRevision(post.ID, fromNumber); !ok {
+			renderHTML(c, http.StatusNotFound, "posts/diff.html", gin.H{"error": "Revision not found"})
+			return
+		}
+	}
+
+	mode := c.DefaultQuery("mode", "line")
+	compare := diff.Lines
+	if mode == "word" {
+		compare = diff.Words
+	} else {
+		mode = "line"
+	}
+
+	var fields []revisionFieldDiff
+	for _, f := range []struct{ name, old, new string }{
+		{"Title", from.Title, to.Title},
+		{"Excerpt", from.Excerpt, to.Excerpt},
+		{"Tags", from.Tags, to.Tags},
+		{"Content", from.Content, to.Content},
+	} {
+		if ops := compare(f.old, f.new); diff.Changed(ops) {
+			fields = append(fields, revisionFieldDiff{Name: f.name, Ops: ops})
+		}
+	}
+
+	title := fmt.Sprintf("Revisions %d and %d of %s", from.Number, to.Number, post.Title)
+	if from.Number == 0 {
+		title = fmt.Sprintf("Revision %d of %s", to.Number, post.Title)
+	}
+	renderHTML(c, http.StatusOK, "posts/diff.html", gin.H{
+		"title":  title,
+		"post":   post,
+		"from":   from,
+		"to":     to,
+		"mode":   mode,
+		"fields": fields,
+	})
+}
+
+// Restore copies an old revision back onto the post. The restored text is
+// recorded as a new revision, so the history is never rewritten.
+func (h *RevisionHandler) Restore(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	post, ok := h.findPost(c, "posts/revision.html")
+	if !ok {
+		return
+	}
+	revision, ok := h.findRevision(post.ID, c.Param("number"))
+	if !ok {
+		renderHTML(c, http.StatusNotFound, "posts/revision.html", gin.H{"error": "Revision not found"})
+		return
+	}
+
+	post.Title = revision.Title
+	post.Content = revision.Content
+	post.Excerpt = revision.Excerpt
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		tags, err := models.FindOrCreateTags(tx, revision.Tags)
+		if err != nil {
+			return err
+		}
+		return savePost(tx, post, tags, userID.(uint), fmt.Sprintf("Restored from revision %d", revision.Number))
+	})
+	if err != nil {
+		renderHTML(c, http.StatusInternalServerError, "posts/revision.html", gin.H{"error": "Failed to restore revision: " + err.Error()})
+		return
+	}
+	c.Redirect(http.StatusFound, fmt.Sprintf("/posts/%d/revisions", post.ID))
+}
+
+// findPost loads the post named by the :id parameter, rendering tpl with an
+// error when it does not exist or the user may not edit it.
+func (h *RevisionHandler) findPost(c *gin.Context, tpl string) (*models.Post, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		renderHTML(c, http.StatusBadRequest, tpl, gin.H{"error": "Invalid post ID"})
+		return nil, false
+	}
+
+	var post models.Post
+	if err := h.db.First(&post, id).Error; err != nil ||
+		!auth.CanModify(c, post.AuthorID, models.PermPostEditOwn, models.PermPostEditAny) {
+		renderHTML(c, http.StatusNotFound, tpl, gin.H{"error": "Post not found"})
+		return nil, false
+	}
+	return &post, true
+}
+
+func (h *RevisionHandler) findRevision(postID uint, number string) (*models.PostRevision, bool) {
+	n, err := strconv.Atoi(number)
+	if err != nil {
+		return nil, false
+	}
+	var revision models.PostRevision
+	if err := h.db.Preload("Editor").Where("post_id = ? AND number = ?", postID, n).First(&revision).Error; err != nil {
+		return nil, false
+	}
+	return &revision, true
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Jason-cqtan/simple-blog/models"
+)
+
+func TestRevisionDiff(t *testing.T) {
+	db := openTestDB(t)
+	author := models.User{Username: "erin", Email: "erin@example.com", Password: "x"}
+	if err := db.Create(&author).Error; err != nil {
+		t.Fatal(err)
+	}
+	post := models.Post{Title: "First", Content: "hello\n", AuthorID: author.ID}
+	if err := db.Create(&post).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	router := newTestRouter(t)
+	h := NewRevisionHandler(db)
+	router.GET("/posts/:id/revisions/diff", signInAs(t, db, author.ID), h.Diff)
+	get := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/posts/"+strconv.Itoa(int(post.ID))+"/revisions/diff"+query, nil))
+		return rec
+	}
+
+	// With a single revision there is no previous one to compare with.
+	for _, query := range []string{"", "?to=1", "?from=0&to=1"} {
+		rec := get(query)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%q: status %d", query, rec.Code)
+		}
+		if body := rec.Body.String(); !strings.Contains(body, "From an empty post") || !strings.Contains(body, "<ins>hello\n</ins>") {
+			t.Errorf("%q: first revision not shown as added:\n%s", query, body)
+		}
+	}
+
+	post.Content = "hello\nworld\n"
+	if err := savePost(db, &post, nil, author.ID, ""); err != nil {
+		t.Fatal(err)
+	}
+	rec := get("")
+	if body := rec.Body.String(); rec.Code != http.StatusOK || !strings.Contains(body, "revision 1</a>") || !strings.Contains(body, "hello\n<ins>world\n</ins>") {
+		t.Errorf("latest against previous: status %d\n%s", rec.Code, body)
+	}
+
+	for _, query := range []string{"?from=7", "?to=7", "?from=x"} {
+		if rec := get(query); rec.Code != http.StatusNotFound {
+			t.Errorf("%q: status %d, want 404", query, rec.Code)
+		}
+	}
+}
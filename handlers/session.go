@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"unicode/utf8"
+
+	"github.com/Jason-cqtan/simple-blog/auth"
+	"github.com/Jason-cqtan/simple-blog/config"
+	"github.com/Jason-cqtan/simple-blog/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// startSession opens a session for userID and sets the auth cookies. The
+// client's user agent and IP are recorded for the sessions page.
+func startSession(c *gin.Context, db *gorm.DB, cfg *config.Config, userID uint, mfa bool) error {
+	_, tokens, err := auth.CreateSession(db, cfg, &models.Session{
+		UserID:    userID,
+		MFA:       mfa,
+		UserAgent: truncate(c.Request.UserAgent(), 255),
+		IP:        c.ClientIP(),
+	})
+	if err != nil {
+		return err
+	}
+	auth.SetCookies(c, cfg, tokens)
+	return nil
+}
+
+// truncate shortens s to at most n bytes without splitting a UTF-8 sequence.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
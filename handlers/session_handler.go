@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Jason-cqtan/simple-blog/auth"
+	"github.com/Jason-cqtan/simple-blog/config"
+	"github.com/Jason-cqtan/simple-blog/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// SessionHandler shows users where they are signed in and lets them end
+// individual sessions.
+type SessionHandler struct {
+	db  *gorm.DB
+	cfg *config.Config
+}
+
+func NewSessionHandler(db *gorm.DB, cfg *config.Config) *SessionHandler {
+	return &SessionHandler{db: db, cfg: cfg}
+}
+
+// List shows the user's active sessions, most recently used first.
+func (h *SessionHandler) List(c *gin.Context) {
+	h.render(c, http.StatusOK, gin.H{})
+}
+
+// Revoke ends one of the user's sessions. Ending the current one signs the
+// user out.
+func (h *SessionHandler) Revoke(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		h.render(c, http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	if err := auth.RevokeUserSession(h.db, userID.(uint), uint(id)); err != nil {
+		h.render(c, http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+
+	if current, _ := c.Get("sessionID"); current == uint(id) {
+		auth.ClearCookies(c, h.cfg)
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+	c.Redirect(http.StatusFound, "/profile/sessions")
+}
+
+func (h *SessionHandler) render(c *gin.Context, status int, extra gin.H) {
+	userID, _ := c.Get("userID")
+	current, _ := c.Get("sessionID")
+
+	var sessions []models.Session
+	h.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("COALESCE(last_seen_at, created_at) desc").
+		Find(&sessions)
+
+	data := gin.H{
+		"title":    "Sessions",
+		"sessions": sessions,
+		"current":  current,
+	}
+	for k, v := range extra {
+		data[k] = v
+	}
+	renderHTML(c, status, "users/sessions.html", data)
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Jason-cqtan/simple-blog/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Tags in the cloud are sized from tagCloudMinSize to tagCloudMaxSize
+// percent of the normal font size, by how many posts carry them.
+const (
+	adminTagsPerPage = 50
+	tagCloudMinSize  = 100
+	tagCloudMaxSize  = 200
+)
+
+// TagHandler serves the public tag pages and the admin tag tools.
+type TagHandler struct {
+	db *gorm.DB
+}
+
+func NewTagHandler(db *gorm.DB) *TagHandler {
+	return &TagHandler{db: db}
+}
+
+// tagCount is a tag with the number of posts carrying it. Size is its font
+// size in the tag cloud, in percent.
+type tagCount struct {
+	models.Tag
+	PostCount int64
+	Size      int
+}
+
+// Index shows a cloud of the tags on published posts, sized by how many
+// posts carry them.
+func (h *TagHandler) Index(c *gin.Context) {
+	var tags []tagCount
+	h.db.Model(&models.Tag{}).
+		Select("tags.*, COUNT(posts.id) AS post_count").
+		Joins("JOIN post_tags ON post_tags.tag_id = tags.id").
+		Joins("JOIN posts ON posts.id = post_tags.post_id AND posts.published = ?", true).
+		Group("tags.id").Order("tags.slug").Scan(&tags)
+
+	var lo, hi int64
+	for i, t := range tags {
+		if i == 0 || t.PostCount < lo {
+			lo = t.PostCount
+		}
+		if t.PostCount > hi {
+			hi = t.PostCount
+		}
+	}
+	for i := range tags {
+		tags[i].Size = tagCloudMinSize
+		if hi > lo {
+			tags[i].Size += int((tags[i].PostCount - lo) * (tagCloudMaxSize - tagCloudMinSize) / (hi - lo))
+		}
+	}
+
+	renderHTML(c, http.StatusOK, "tags/index.html", gin.H{
+		"title": "Tags",
+		"tags":  tags,
+	})
+}
+
+// Show lists the published posts with a tag, newest first.
+func (h *TagHandler) Show(c *gin.Context) {
+	var tag models.Tag
+	if err := h.db.Where("slug = ?", c.Param("slug")).First(&tag).Error; err != nil {
+		renderHTML(c, http.StatusNotFound, "tags/show.html", gin.H{"title": "Tag not found", "error": "Tag not found"})
+		return
+	}
+
+	tagged := func() *gorm.DB {
+		return h.db.Model(&models.Post{}).
+			Joins("JOIN post_tags ON post_tags.post_id = posts.id").
+			Where("post_tags.tag_id = ? AND posts.published = ?", tag.ID, true)
+	}
+	var total int64
+	tagged().Count(&total)
+	pagination := newPagination(c, total, postsPerPage, maxPostsPerPage)
+
+	var posts []models.Post
+	pagination.Scope(tagged().Preload("Author").Order("posts.published_at desc")).Find(&posts)
+
+	renderHTML(c, http.StatusOK, "tags/show.html", gin.H{
+		"title":      "Posts tagged " + tag.Name,
+		"tag":        tag,
+		"posts":      posts,
+		"pagination": pagination,
+	})
+}
+
+// AdminList shows every tag with the number of posts, in any state, that
+// carry it.
+func (h *TagHandler) AdminList(c *gin.Context) {
+	h.renderAdmin(c, http.StatusOK, gin.H{})
+}
+
+// Rename changes a tag's name and slug.
+func (h *TagHandler) Rename(c *gin.Context) {
+	tag, ok := h.findTag(c)
+	if !ok {
+		return
+	}
+	if err := models.RenameTag(h.db, tag, c.PostForm("name")); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, models.ErrTagExists) || errors.Is(err, models.ErrTagNameTooLong) || errors.Is(err, models.ErrTagNameEmpty) {
+			status = http.StatusBadRequest
+		}
+		h.renderAdmin(c, status, gin.H{"error": err.Error()})
+		return
+	}
+	c.Redirect(http.StatusFound, "/admin/tags")
+}
+
+// Merge moves a tag's posts to the tag named in the "into" field and
+// deletes it.
+func (h *TagHandler) Merge(c *gin.Context) {
+	tag, ok := h.findTag(c)
+	if !ok {
+		return
+	}
+	into, err := models.FindTagByName(h.db, c.PostForm("into"))
+	if err != nil || into.ID == tag.ID {
+		h.renderAdmin(c, http.StatusBadRequest, gin.H{"error": "Choose another existing tag to merge into"})
+		return
+	}
+	if err := models.MergeTags(h.db, tag, into); err != nil {
+		h.renderAdmin(c, http.StatusInternalServerError, gin.H{"error": "Failed to merge tags"})
+		return
+	}
+	c.Redirect(http.StatusFound, "/admin/tags")
+}
+
+func (h *TagHandler) findTag(c *gin.Context) (*models.Tag, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	var tag models.Tag
+	if err != nil || h.db.First(&tag, id).Error != nil {
+		h.renderAdmin(c, http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return nil, false
+	}
+	return &tag, true
+}
+
+func (h *TagHandler) renderAdmin(c *gin.Context, status int, extra gin.H) {
+	var total int64
+	h.db.Model(&models.Tag{}).Count(&total)
+	pagination := newPagination(c, total, adminTagsPerPage, adminMaxPerPage)
+
+	var tags []tagCount
+	pagination.Scope(h.db.Model(&models.Tag{}).
+		Select("tags.*, COUNT(post_tags.post_id) AS post_count").
+		Joins("LEFT JOIN post_tags ON post_tags.tag_id = tags.id").
+		Group("tags.id").Order("tags.slug")).Scan(&tags)
+
+	data := gin.H{
+		"title":      "Tags",
+		"tags":       tags,
+		"pagination": pagination,
+	}
+	for k, v := range extra {
+		data[k] = v
+	}
+	renderHTML(c, status, "admin/tags.html", data)
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Jason-cqtan/simple-blog/auth"
+	"github.com/Jason-cqtan/simple-blog/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxTokenLifetimeDays caps the optional expiry users can pick.
+const maxTokenLifetimeDays = 3650
+
+type TokenHandler struct {
+	db *gorm.DB
+}
+
+func NewTokenHandler(db *gorm.DB) *TokenHandler {
+	return &TokenHandler{db: db}
+}
+
+func (h *TokenHandler) List(c *gin.Context) {
+	h.render(c, http.StatusOK, nil)
+}
+
+func (h *TokenHandler) Create(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	name := strings.TrimSpace(c.PostForm("name"))
+	if name == "" || len(name) > 100 {
+		h.render(c, http.StatusBadRequest, gin.H{"error": "Name is required (at most 100 characters)"})
+		return
+	}
+	scopes := c.PostFormArray("scopes")
+	if len(scopes) == 0 {
+		h.render(c, http.StatusBadRequest, gin.H{"error": "Select at least one scope"})
+		return
+	}
+
+	var expiresAt *time.Time
+	if days := c.PostForm("expires_in_days"); days != "" {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 1 || n > maxTokenLifetimeDays {
+			h.render(c, http.StatusBadRequest, gin.H{"error": "Expiry must be between 1 and 3650 days"})
+			return
+		}
+		t := time.Now().AddDate(0, 0, n)
+		expiresAt = &t
+	}
+
+	plain, _, err := auth.CreatePersonalToken(h.db, userID.(uint), name, scopes, expiresAt, c.GetBool("mfa"))
+	if err == auth.ErrInvalidScope {
+		h.render(c, http.StatusBadRequest, gin.H{"error": "Unknown scope"})
+		return
+	}
+	if err != nil {
+		h.render(c, http.StatusInternalServerError, gin.H{"error": "Failed to create token"})
+		return
+	}
+
+	h.render(c, http.StatusOK, gin.H{"new_token": plain})
+}
+
+func (h *TokenHandler) Revoke(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		h.render(c, http.StatusBadRequest, gin.H{"error": "Invalid token ID"})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	if err := auth.RevokePersonalToken(h.db, userID.(uint), uint(id)); err != nil {
+		h.render(c, http.StatusNotFound, gin.H{"error": "Token not found"})
+		return
+	}
+	c.Redirect(http.StatusFound, "/profile/tokens")
+}
+
+func (h *TokenHandler) render(c *gin.Context, status int, extra gin.H) {
+	userID, _ := c.Get("userID")
+
+	var tokens []models.PersonalAccessToken
+	h.db.Where("user_id = ? AND revoked_at IS NULL", userID).Order("created_at desc").Find(&tokens)
+
+	data := gin.H{
+		"title":  "Access Tokens",
+		"tokens": tokens,
+		"scopes": models.AllScopes,
+	}
+	for k, v := range extra {
+		data[k] = v
+	}
+	renderHTML(c, status, "users/tokens.html", data)
+}
@@ -1,44 +1,80 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 
+	"github.com/Jason-cqtan/simple-blog/auth"
 	"github.com/Jason-cqtan/simple-blog/config"
+	"github.com/Jason-cqtan/simple-blog/mailer"
 	"github.com/Jason-cqtan/simple-blog/models"
+	"github.com/Jason-cqtan/simple-blog/throttle"
 	"github.com/Jason-cqtan/simple-blog/utils"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
+// mfaLoginTTL is how long a user has to enter their second factor after
+// their password was accepted.
+const mfaLoginTTL = 5 * time.Minute
+
 type UserHandler struct {
-	db  *gorm.DB
-	cfg *config.Config
+	db    *gorm.DB
+	cfg   *config.Config
+	mail  mailer.Mailer
+	guard *throttle.LoginGuard
 }
 
-func NewUserHandler(db *gorm.DB, cfg *config.Config) *UserHandler {
-	return &UserHandler{db: db, cfg: cfg}
+func NewUserHandler(db *gorm.DB, cfg *config.Config, mail mailer.Mailer, guard *throttle.LoginGuard) *UserHandler {
+	return &UserHandler{db: db, cfg: cfg, mail: mail, guard: guard}
 }
 
 func (h *UserHandler) ShowRegisterForm(c *gin.Context) {
-	c.HTML(http.StatusOK, "users/register.html", gin.H{"title": "Register"})
+	status := http.StatusOK
+	if h.cfg.RegistrationMode == config.RegistrationClosed {
+		status = http.StatusForbidden
+	}
+	h.renderRegister(c, status, gin.H{"invite": c.Query("invite")})
 }
 
+// Register creates an account. Depending on REGISTRATION_MODE sign-up is
+// open, needs a valid invite code, or is refused.
 func (h *UserHandler) Register(c *gin.Context) {
+	if h.cfg.RegistrationMode == config.RegistrationClosed {
+		h.renderRegister(c, http.StatusForbidden, gin.H{})
+		return
+	}
+
 	username := c.PostForm("username")
 	email := c.PostForm("email")
 	password := c.PostForm("password")
+	code := c.PostForm("invite")
+	form := gin.H{"invite": code}
+
+	fail := func(status int, msg string) {
+		form["error"] = msg
+		h.renderRegister(c, status, form)
+	}
 
 	if err := utils.ValidateUsername(username); err != nil {
-		c.HTML(http.StatusBadRequest, "users/register.html", gin.H{"error": err.Error()})
+		fail(http.StatusBadRequest, err.Error())
+		return
+	}
+	if models.IsReservedUsername(username) {
+		fail(http.StatusBadRequest, "Username is already taken")
 		return
 	}
 	if err := utils.ValidateEmail(email); err != nil {
-		c.HTML(http.StatusBadRequest, "users/register.html", gin.H{"error": err.Error()})
+		fail(http.StatusBadRequest, err.Error())
 		return
 	}
-	if err := utils.ValidatePassword(password); err != nil {
-		c.HTML(http.StatusBadRequest, "users/register.html", gin.H{"error": err.Error()})
+	if err := h.cfg.PasswordPolicy.Validate(password, username, email); err != nil {
+		fail(http.StatusBadRequest, err.Error())
 		return
 	}
 
@@ -46,68 +82,339 @@ func (h *UserHandler) Register(c *gin.Context) {
 		Username: username,
 		Email:    email,
 	}
-	if err := user.HashPassword(password); err != nil {
-		c.HTML(http.StatusInternalServerError, "users/register.html", gin.H{"error": "Failed to process password"})
+	if err := user.HashPassword(h.cfg.PasswordHasher, password); err != nil {
+		fail(http.StatusInternalServerError, "Failed to process password")
 		return
 	}
 
-	if err := h.db.Create(&user).Error; err != nil {
-		c.HTML(http.StatusBadRequest, "users/register.html", gin.H{"error": "Username or email already exists"})
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if h.cfg.RegistrationMode == config.RegistrationInvite {
+			invite, err := auth.RedeemInvite(tx, code)
+			if err != nil {
+				return err
+			}
+			user.InviteID = &invite.ID
+		}
+		return tx.Create(&user).Error
+	})
+	if errors.Is(err, auth.ErrInvalidInvite) {
+		fail(http.StatusBadRequest, "Invite code is invalid, expired or already used")
+		return
+	}
+	if err != nil {
+		fail(http.StatusBadRequest, "Username or email already exists")
 		return
 	}
 
+	if err := sendVerificationEmail(h.mail, h.cfg, &user); err != nil {
+		log.Printf("verification email for user %d: %v", user.ID, err)
+	}
+
 	c.Redirect(http.StatusFound, "/login")
 }
 
+func (h *UserHandler) renderRegister(c *gin.Context, status int, extra gin.H) {
+	data := gin.H{
+		"title":       "Register",
+		"closed":      h.cfg.RegistrationMode == config.RegistrationClosed,
+		"invite_only": h.cfg.RegistrationMode == config.RegistrationInvite,
+	}
+	for k, v := range extra {
+		data[k] = v
+	}
+	renderHTML(c, status, "users/register.html", data)
+}
+
+func (h *UserHandler) VerifyEmail(c *gin.Context) {
+	claims, err := utils.ParseEmailToken(c.Query("token"), h.cfg.Keyring)
+	if err != nil {
+		renderHTML(c, http.StatusBadRequest, "users/verify_email.html", gin.H{"title": "Verify Email", "error": "Invalid or expired verification link"})
+		return
+	}
+
+	// Matching on the address as well makes links sent to a previous address useless.
+	result := h.db.Model(&models.User{}).
+		Where("id = ? AND email = ? AND email_verified_at IS NULL", claims.UserID, claims.Email).
+		Update("email_verified_at", time.Now())
+	if result.Error != nil {
+		renderHTML(c, http.StatusInternalServerError, "users/verify_email.html", gin.H{"title": "Verify Email", "error": "Failed to verify email"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		var count int64
+		h.db.Model(&models.User{}).Where("id = ? AND email = ?", claims.UserID, claims.Email).Count(&count)
+		if count == 0 {
+			renderHTML(c, http.StatusBadRequest, "users/verify_email.html", gin.H{"title": "Verify Email", "error": "Invalid or expired verification link"})
+			return
+		}
+	}
+
+	renderHTML(c, http.StatusOK, "users/verify_email.html", gin.H{"title": "Verify Email", "message": "Your email address has been verified."})
+}
+
+func (h *UserHandler) ResendVerification(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var user models.User
+	if err := h.db.First(&user, userID).Error; err != nil {
+		renderHTML(c, http.StatusNotFound, "users/verify_email.html", gin.H{"title": "Verify Email", "error": "User not found"})
+		return
+	}
+	if user.EmailVerified() {
+		renderHTML(c, http.StatusOK, "users/verify_email.html", gin.H{"title": "Verify Email", "message": "Your email address is already verified."})
+		return
+	}
+
+	if err := sendVerificationEmail(h.mail, h.cfg, &user); err != nil {
+		log.Printf("verification email for user %d: %v", user.ID, err)
+		renderHTML(c, http.StatusInternalServerError, "users/verify_email.html", gin.H{"title": "Verify Email", "error": "Failed to send verification email"})
+		return
+	}
+
+	renderHTML(c, http.StatusOK, "users/verify_email.html", gin.H{"title": "Verify Email", "message": "A new verification link has been sent to " + user.Email + "."})
+}
+
+// sendVerificationEmail mails user a link confirming their current address.
+func sendVerificationEmail(mail mailer.Mailer, cfg *config.Config, user *models.User) error {
+	token, err := utils.GenerateEmailToken(user.ID, user.Email, cfg.Keyring, cfg.EmailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	link := cfg.BaseURL + "/verify-email?token=" + url.QueryEscape(token)
+	return mail.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Confirm your Simple Blog email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening the link below within %s:\n\n%s\n\n"+
+			"If you did not create this account, you can ignore this email.\n",
+			user.Username, cfg.EmailVerificationTTL, link),
+	})
+}
+
 func (h *UserHandler) ShowLoginForm(c *gin.Context) {
-	c.HTML(http.StatusOK, "users/login.html", gin.H{"title": "Login"})
+	data := gin.H{"title": "Login"}
+	if h.cfg.OIDCIssuer != "" {
+		data["sso_name"] = h.cfg.OIDCProviderName
+	}
+	renderHTML(c, http.StatusOK, "users/login.html", data)
 }
 
 func (h *UserHandler) Login(c *gin.Context) {
 	email := c.PostForm("email")
 	password := c.PostForm("password")
+	ip, account := c.ClientIP(), throttle.AccountKey(email)
+
+	if h.throttled(c, "users/login.html", gin.H{}, ip, account) {
+		return
+	}
 
 	var user models.User
-	if err := h.db.Where("email = ?", email).First(&user).Error; err != nil {
-		c.HTML(http.StatusUnauthorized, "users/login.html", gin.H{"error": "Invalid credentials"})
+	if err := h.db.Where("email = ?", email).First(&user).Error; err != nil || !user.CheckPassword(password) {
+		h.recordFailure(ip, account)
+		renderHTML(c, http.StatusUnauthorized, "users/login.html", gin.H{"error": "Invalid credentials"})
 		return
 	}
+	h.recordSuccess(account)
+	h.rehashPassword(&user, password)
 
-	if !user.CheckPassword(password) {
-		c.HTML(http.StatusUnauthorized, "users/login.html", gin.H{"error": "Invalid credentials"})
+	if user.Blocked(time.Now()) {
+		renderHTML(c, http.StatusForbidden, "users/login.html", gin.H{"error": user.BlockedMessage()})
+		return
+	}
+	if user.PasswordResetRequired {
+		renderHTML(c, http.StatusForbidden, "users/login.html", gin.H{"error": "You must choose a new password before signing in. Use the reset link we emailed you, or request a new one."})
 		return
 	}
 
-	token, err := utils.GenerateToken(user.ID, h.cfg.JWTSecret)
+	if user.TOTPEnabled {
+		mfaToken, err := utils.GenerateMFAToken(user.ID, h.cfg.Keyring, mfaLoginTTL)
+		if err != nil {
+			renderHTML(c, http.StatusInternalServerError, "users/login.html", gin.H{"error": "Failed to generate token"})
+			return
+		}
+		renderHTML(c, http.StatusOK, "users/login_2fa.html", gin.H{"title": "Two-Factor Authentication", "mfa_token": mfaToken})
+		return
+	}
+
+	if err := startSession(c, h.db, h.cfg, user.ID, false); err != nil {
+		renderHTML(c, http.StatusInternalServerError, "users/login.html", gin.H{"error": "Failed to generate token"})
+		return
+	}
+	c.Redirect(http.StatusFound, "/")
+}
+
+// rehashPassword upgrades the user's stored hash to the configured algorithm
+// and parameters once the plain password is known to be correct. Failures
+// are logged; the old hash keeps working.
+func (h *UserHandler) rehashPassword(user *models.User, password string) {
+	if !h.cfg.PasswordHasher.NeedsRehash(user.Password) {
+		return
+	}
+	hashed, err := h.cfg.PasswordHasher.Hash(password)
+	if err == nil {
+		err = h.db.Model(&models.User{ID: user.ID}).Where("password = ?", user.Password).Update("password", hashed).Error
+	}
+	if err != nil {
+		log.Printf("rehash password of user %d: %v", user.ID, err)
+		return
+	}
+	user.Password = hashed
+}
+
+// LoginMFA is the second login step for accounts with two-factor
+// authentication: it accepts a TOTP code or a recovery code.
+func (h *UserHandler) LoginMFA(c *gin.Context) {
+	mfaToken := c.PostForm("mfa_token")
+	claims, err := utils.ParseMFAToken(mfaToken, h.cfg.Keyring)
 	if err != nil {
-		c.HTML(http.StatusInternalServerError, "users/login.html", gin.H{"error": "Failed to generate token"})
+		renderHTML(c, http.StatusUnauthorized, "users/login.html", gin.H{"title": "Login", "error": "Your login attempt expired, please sign in again"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, claims.UserID).Error; err != nil || !user.TOTPEnabled {
+		renderHTML(c, http.StatusUnauthorized, "users/login.html", gin.H{"title": "Login", "error": "Invalid credentials"})
+		return
+	}
+
+	ip, account := c.ClientIP(), throttle.SecondFactorKey(user.ID)
+	if h.throttled(c, "users/login_2fa.html", gin.H{"title": "Two-Factor Authentication", "mfa_token": mfaToken}, ip, account) {
 		return
 	}
 
-	c.SetCookie("token", token, int(24*time.Hour.Seconds()), "/", "", h.cfg.SecureCookie, true)
+	if !auth.VerifySecondFactor(h.db, &user, c.PostForm("code")) {
+		h.recordFailure(ip, account)
+		renderHTML(c, http.StatusUnauthorized, "users/login_2fa.html", gin.H{"title": "Two-Factor Authentication", "mfa_token": mfaToken, "error": "Invalid authentication code"})
+		return
+	}
+
+	h.recordSuccess(account)
+
+	if user.Blocked(time.Now()) {
+		renderHTML(c, http.StatusForbidden, "users/login.html", gin.H{"title": "Login", "error": user.BlockedMessage()})
+		return
+	}
+
+	if err := startSession(c, h.db, h.cfg, user.ID, true); err != nil {
+		renderHTML(c, http.StatusInternalServerError, "users/login.html", gin.H{"error": "Failed to generate token"})
+		return
+	}
 	c.Redirect(http.StatusFound, "/")
 }
 
+// throttled renders tpl with a 429 when the client IP or the account is
+// locked out after too many failed attempts.
+func (h *UserHandler) throttled(c *gin.Context, tpl string, data gin.H, ip, account string) bool {
+	wait, err := h.guard.Wait(ip, account)
+	if err != nil {
+		data["error"] = "Failed to check login attempts"
+		renderHTML(c, http.StatusInternalServerError, tpl, data)
+		return true
+	}
+	if wait <= 0 {
+		return false
+	}
+
+	seconds := int(wait.Round(time.Second) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	data["error"] = fmt.Sprintf("Too many failed attempts. Try again in %s.", time.Duration(seconds)*time.Second)
+	renderHTML(c, http.StatusTooManyRequests, tpl, data)
+	return true
+}
+
+func (h *UserHandler) recordFailure(ip, account string) {
+	if err := h.guard.Fail(ip, account); err != nil {
+		log.Printf("Failed to record login failure: %v", err)
+	}
+}
+
+func (h *UserHandler) recordSuccess(account string) {
+	if err := h.guard.Succeed(account); err != nil {
+		log.Printf("Failed to clear login failures: %v", err)
+	}
+}
+
+// Refresh exchanges a refresh token, taken from the request body or the
+// refresh cookie, for a new access/refresh token pair.
+func (h *UserHandler) Refresh(c *gin.Context) {
+	refresh := c.PostForm("refresh_token")
+	fromCookie := false
+	if refresh == "" {
+		if cookie, err := c.Cookie(auth.RefreshCookie); err == nil {
+			refresh = cookie
+			fromCookie = true
+		}
+	}
+
+	session, tokens, err := auth.RotateSession(h.db, h.cfg, refresh)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, session.UserID).Error; err != nil || user.Blocked(time.Now()) {
+		_ = auth.RevokeSession(h.db, session.ID)
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is not active"})
+		return
+	}
+	auth.TouchSession(h.db, session, c.ClientIP())
+
+	if fromCookie {
+		auth.SetCookies(c, h.cfg, tokens)
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
 func (h *UserHandler) Logout(c *gin.Context) {
-	c.SetCookie("token", "", -1, "/", "", h.cfg.SecureCookie, true)
+	if refresh, err := c.Cookie(auth.RefreshCookie); err == nil {
+		var session models.Session
+		if h.db.Where("refresh_token_hash = ?", utils.HashToken(refresh)).First(&session).Error == nil {
+			_ = auth.RevokeSession(h.db, session.ID)
+		}
+	} else if token, err := c.Cookie(auth.AccessCookie); err == nil {
+		if claims, err := utils.ParseToken(token, h.cfg.Keyring); err == nil {
+			_ = auth.RevokeSession(h.db, claims.SessionID)
+		}
+	}
+
+	auth.ClearCookies(c, h.cfg)
 	c.Redirect(http.StatusFound, "/")
 }
 
+// LogoutAll ends every session of the current user, on all devices.
+func (h *UserHandler) LogoutAll(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	if err := auth.RevokeUserSessions(h.db, userID.(uint)); err != nil {
+		renderHTML(c, http.StatusInternalServerError, "users/profile.html", gin.H{"error": "Failed to sign out other sessions"})
+		return
+	}
+
+	auth.ClearCookies(c, h.cfg)
+	c.Redirect(http.StatusFound, "/login")
+}
+
 func (h *UserHandler) ShowProfile(c *gin.Context) {
 	userID, _ := c.Get("userID")
 
 	var user models.User
 	if err := h.db.First(&user, userID).Error; err != nil {
-		c.HTML(http.StatusNotFound, "users/profile.html", gin.H{"error": "User not found"})
+		renderHTML(c, http.StatusNotFound, "users/profile.html", gin.H{"error": "User not found"})
 		return
 	}
 
 	var posts []models.Post
-	h.db.Where("author_id = ?", userID).Find(&posts)
+	h.db.Where("author_id = ?", userID).Order("created_at desc").Find(&posts)
 
-	c.HTML(http.StatusOK, "users/profile.html", gin.H{
-		"title": user.Username + "'s Profile",
-		"user":  user,
-		"posts": posts,
+	renderHTML(c, http.StatusOK, "users/profile.html", gin.H{
+		"title":            user.Username + "'s Profile",
+		"user":             &user,
+		"posts":            posts,
+		"can_invite":       auth.Can(c, models.PermUserInvite),
+		"can_manage_users": auth.Can(c, models.PermUserManage),
 	})
 }
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Jason-cqtan/simple-blog/mailer"
+	"github.com/Jason-cqtan/simple-blog/models"
+	"github.com/Jason-cqtan/simple-blog/throttle"
+	"github.com/Jason-cqtan/simple-blog/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestLoginRehashesPassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		status   int
+		rehashed bool
+	}{
+		{name: "right password", password: "wintergreen7", status: http.StatusFound, rehashed: true},
+		{name: "wrong password", password: "nope", status: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t)
+			cfg := newTestConfig(t)
+			cfg.PasswordHasher = &utils.Argon2idHasher{Memory: 64, Iterations: 1, Parallelism: 1}
+
+			// The account predates the switch to argon2id.
+			user := models.User{Username: "dana", Email: "dana@example.com"}
+			if err := user.HashPassword(&utils.BcryptHasher{Cost: bcrypt.MinCost}, "wintergreen7"); err != nil {
+				t.Fatal(err)
+			}
+			if err := db.Create(&user).Error; err != nil {
+				t.Fatal(err)
+			}
+			oldHash := user.Password
+
+			guard := throttle.NewLoginGuard(throttle.NewMemoryStore(), cfg)
+			h := NewUserHandler(db, cfg, mailer.NewMemoryMailer(), guard)
+			router := newTestRouter(t)
+			router.POST("/login", h.Login)
+
+			form := url.Values{"email": {user.Email}, "password": {tt.password}}
+			req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+
+			if err := db.First(&user, user.ID).Error; err != nil {
+				t.Fatal(err)
+			}
+			if rehashed := user.Password != oldHash; rehashed != tt.rehashed {
+				t.Fatalf("rehashed = %v, want %v", rehashed, tt.rehashed)
+			}
+			if tt.rehashed {
+				if cfg.PasswordHasher.NeedsRehash(user.Password) {
+					t.Errorf("stored hash %q was not made by the configured hasher", user.Password)
+				}
+				if !user.CheckPassword("wintergreen7") {
+					t.Error("the new hash does not verify the password")
+				}
+			}
+		})
+	}
+}
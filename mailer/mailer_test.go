@@ -0,0 +1,98 @@
+package mailer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Jason-cqtan/simple-blog/config"
+)
+
+func TestMemoryMailer(t *testing.T) {
+	m := NewMemoryMailer()
+	first := Message{To: "a@example.com", Subject: "One", Body: "first"}
+	second := Message{To: "b@example.com", Subject: "Two", Body: "second"}
+	for _, msg := range []Message{first, second} {
+		if err := m.Send(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := m.Messages()
+	if len(got) != 2 || got[0] != first || got[1] != second {
+		t.Fatalf("Messages() = %+v, want [%+v %+v]", got, first, second)
+	}
+
+	// The returned slice is a copy.
+	got[0].Subject = "changed"
+	if m.Messages()[0] != first {
+		t.Error("changing the result of Messages changed the recorded message")
+	}
+
+	m.Reset()
+	if got := m.Messages(); len(got) != 0 {
+		t.Errorf("Messages() after Reset = %+v, want none", got)
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "outbox")
+	m, err := NewFileMailer(dir, "blog@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := m.Send(Message{To: "a@example.com", Subject: "Hello", Body: "Hi there"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("outbox has %d files, want 2", len(entries))
+	}
+	data, err := os.ReadFile(filepath.Join(dir, entries[0].Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"From: blog@example.com", "To: a@example.com", "Subject: Hello", "Hi there"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("message file lacks %q:\n%s", want, data)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		driver  string
+		want    string
+		wantErr bool
+	}{
+		{driver: "memory", want: "*mailer.MemoryMailer"},
+		{driver: "file", want: "*mailer.FileMailer"},
+		{driver: "smtp", want: "*mailer.SMTPMailer"},
+		{driver: "carrier-pigeon", wantErr: true},
+	}
+	for _, tt := range tests {
+		cfg := &config.Config{MailDriver: tt.driver, MailOutboxDir: t.TempDir()}
+		m, err := New(cfg)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("New(%q) succeeded, want an error", tt.driver)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("New(%q): %v", tt.driver, err)
+			continue
+		}
+		if got := fmt.Sprintf("%T", m); got != tt.want {
+			t.Errorf("New(%q) = %s, want %s", tt.driver, got, tt.want)
+		}
+	}
+}
@@ -1,20 +1,29 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/Jason-cqtan/simple-blog/auth"
 	"github.com/Jason-cqtan/simple-blog/config"
 	"github.com/Jason-cqtan/simple-blog/database"
+	"github.com/Jason-cqtan/simple-blog/mailer"
+	"github.com/Jason-cqtan/simple-blog/middleware"
 	"github.com/Jason-cqtan/simple-blog/routes"
+	"github.com/Jason-cqtan/simple-blog/scheduler"
+	"github.com/Jason-cqtan/simple-blog/throttle"
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
 	seed := flag.Bool("seed", false, "initialize the database and load seed data, then exit")
+	revokeUser := flag.Uint("revoke-sessions", 0, "revoke every active session of the given user ID, then exit")
+	setRole := flag.String("set-role", "", "assign a role given as username:role (admin, editor, author, reader), then exit")
 	flag.Parse()
 
 	cfg := config.LoadConfig()
@@ -25,13 +34,48 @@ func main() {
 	}
 
 	if *seed {
-		if err := database.Seed(db); err != nil {
+		if err := database.Seed(db, cfg.PasswordHasher); err != nil {
 			log.Fatalf("Failed to seed database: %v", err)
 		}
 		return
 	}
 
+	if *revokeUser != 0 {
+		if err := auth.RevokeUserSessions(db, uint(*revokeUser)); err != nil {
+			log.Fatalf("Failed to revoke sessions: %v", err)
+		}
+		log.Printf("Revoked all sessions of user %d.", *revokeUser)
+		return
+	}
+
+	if *setRole != "" {
+		username, role, ok := strings.Cut(*setRole, ":")
+		if !ok {
+			log.Fatalf("Invalid -set-role value %q, expected username:role", *setRole)
+		}
+		if err := database.AssignRole(db, username, role); err != nil {
+			log.Fatalf("Failed to assign role: %v", err)
+		}
+		log.Printf("Assigned role %s to %s.", role, username)
+		return
+	}
+
+	mail, err := mailer.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to set up mailer: %v", err)
+	}
+
+	throttleStore, err := throttle.NewStore(cfg, db)
+	if err != nil {
+		log.Fatalf("Failed to set up login throttling: %v", err)
+	}
+	guard := throttle.NewLoginGuard(throttleStore, cfg)
+
 	router := gin.Default()
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+	router.MaxMultipartMemory = middleware.MultipartMemory
 
 	// Collect all .html files under views/ (including root-level files like views/home.html)
 	var htmlFiles []string
@@ -49,8 +93,11 @@ func main() {
 	router.LoadHTMLFiles(htmlFiles...)
 
 	router.Static("/static", "./static")
+	router.Static("/media", cfg.MediaDir)
+
+	routes.SetupRoutes(router, db, cfg, mail, guard)
 
-	routes.SetupRoutes(router, db, cfg)
+	go scheduler.Run(context.Background(), db, cfg.PostSchedulerInterval)
 
 	addr := fmt.Sprintf(":%s", cfg.ServerPort)
 	log.Printf("Server starting on %s", addr)
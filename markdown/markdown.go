@@ -0,0 +1,59 @@
+// Package markdown renders post content written in CommonMark with GitHub
+// Flavored Markdown extensions to sanitized HTML.
+package markdown
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"html/template"
+	"regexp"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+// Version identifies the renderer and sanitizer settings. Bump it whenever
+// they change so cached HTML made by the old settings is rendered again.
+const Version = "1"
+
+var (
+	md = goldmark.New(
+		goldmark.WithExtensions(extension.GFM, extension.Footnote),
+		// Raw HTML is passed through and then cleaned up by policy, so
+		// harmless inline markup such as <kbd> keeps working.
+		goldmark.WithRendererOptions(html.WithUnsafe()),
+	)
+	policy = newPolicy()
+)
+
+// newPolicy allows what user-generated HTML may safely contain, plus the
+// markup goldmark itself produces for code blocks, task lists and footnotes.
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowElements("kbd")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+-]+$`)).OnElements("code")
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").Matching(regexp.MustCompile(`^$`)).OnElements("input")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^footnote(s|-ref|-backref)$`)).OnElements("a", "div")
+	p.AllowAttrs("role").Matching(regexp.MustCompile(`^doc-(noteref|endnotes|backlink)$`)).OnElements("a", "div")
+	return p
+}
+
+// Render converts source to sanitized HTML that is safe to embed in a page.
+func Render(source string) (template.HTML, error) {
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(source), &buf); err != nil {
+		return "", err
+	}
+	return template.HTML(policy.SanitizeBytes(buf.Bytes())), nil
+}
+
+// Key identifies the rendering of source under the current Version; cached
+// HTML is valid as long as its key matches.
+func Key(source string) string {
+	sum := sha256.Sum256([]byte(Version + "\x00" + source))
+	return hex.EncodeToString(sum[:])
+}
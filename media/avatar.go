@@ -0,0 +1,101 @@
+// Package media validates, resizes and stores user-uploaded images.
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+
+	// Register the accepted upload formats with image.Decode.
+	_ "image/gif"
+	_ "image/jpeg"
+
+	_ "golang.org/x/image/webp"
+
+	"github.com/Jason-cqtan/simple-blog/utils"
+	"golang.org/x/image/draw"
+)
+
+// AvatarDir is the subdirectory of the media directory holding avatars.
+const AvatarDir = "avatars"
+
+// maxAvatarPixels bounds the decoded size of an upload, so a small file
+// cannot expand into a huge bitmap.
+const maxAvatarPixels = 40_000_000
+
+var (
+	ErrTooLarge         = errors.New("image file is too large")
+	ErrUnsupportedImage = errors.New("image must be a JPEG, PNG, GIF or WebP file")
+	ErrImageDimensions  = errors.New("image dimensions are too large")
+)
+
+// SaveAvatar decodes the uploaded image, crops it to a centred square,
+// scales it to size×size and writes it as PNG under dir/avatars. It returns
+// the stored file name, relative to dir.
+func SaveAvatar(dir string, src io.Reader, maxBytes int64, size int) (string, error) {
+	data, err := io.ReadAll(io.LimitReader(src, maxBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if int64(len(data)) > maxBytes {
+		return "", ErrTooLarge
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", ErrUnsupportedImage
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxAvatarPixels {
+		return "", ErrImageDimensions
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", ErrUnsupportedImage
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, squareThumbnail(img, size)); err != nil {
+		return "", err
+	}
+
+	random, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return "", err
+	}
+	name := filepath.ToSlash(filepath.Join(AvatarDir, random+".png"))
+	if err := os.MkdirAll(filepath.Join(dir, AvatarDir), 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), buf.Bytes(), 0o644); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// Remove deletes a stored file. Names outside dir are ignored.
+func Remove(dir, name string) error {
+	if name == "" || !filepath.IsLocal(name) {
+		return nil
+	}
+	err := os.Remove(filepath.Join(dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func squareThumbnail(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	side := min(b.Dx(), b.Dy())
+	x0 := b.Min.X + (b.Dx()-side)/2
+	y0 := b.Min.Y + (b.Dy()-side)/2
+	crop := image.Rect(x0, y0, x0+side, y0+side)
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, crop, draw.Src, nil)
+	return dst
+}
@@ -3,18 +3,31 @@ package middleware
 import (
 	"net/http"
 	"strings"
+	"time"
 
+	"github.com/Jason-cqtan/simple-blog/auth"
+	"github.com/Jason-cqtan/simple-blog/config"
+	"github.com/Jason-cqtan/simple-blog/models"
 	"github.com/Jason-cqtan/simple-blog/utils"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
-func JWTAuthMiddleware(secret string) gin.HandlerFunc {
+// Values of the "authMethod" context key.
+const (
+	authMethodSession = "session"
+	authMethodToken   = "token"
+)
+
+func JWTAuthMiddleware(db *gorm.DB, cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		tokenString := ""
+		fromCookie := false
 
 		// Try cookie first
-		if cookie, err := c.Cookie("token"); err == nil {
+		if cookie, err := c.Cookie(auth.AccessCookie); err == nil {
 			tokenString = cookie
+			fromCookie = true
 		}
 
 		// Try Authorization header
@@ -25,20 +38,87 @@ func JWTAuthMiddleware(secret string) gin.HandlerFunc {
 			}
 		}
 
-		if tokenString == "" {
-			c.Redirect(http.StatusFound, "/login")
-			c.Abort()
+		// Personal access tokens are only accepted as bearer tokens.
+		if !fromCookie && strings.HasPrefix(tokenString, auth.PersonalTokenPrefix) {
+			authenticatePersonalToken(c, db, tokenString)
 			return
 		}
 
-		claims, err := utils.ParseToken(tokenString, secret)
+		var session *models.Session
+		claims, err := utils.ParseToken(tokenString, cfg.Keyring)
+		if err == nil {
+			session, err = auth.ValidateSession(db, claims)
+		}
+
+		// Browser sessions transparently rotate an expired access token using
+		// the refresh cookie; bearer clients must call /auth/refresh themselves.
+		if err != nil && (fromCookie || tokenString == "") {
+			if refresh, cookieErr := c.Cookie(auth.RefreshCookie); cookieErr == nil {
+				rotated, tokens, rotateErr := auth.RotateSession(db, cfg, refresh)
+				if rotateErr == nil {
+					session = rotated
+					auth.SetCookies(c, cfg, tokens)
+					claims = &utils.Claims{UserID: session.UserID, SessionID: session.ID, MFA: session.MFA}
+					err = nil
+				}
+			}
+		}
+
+		var user *models.User
+		if err == nil {
+			user, err = auth.LoadUser(db, claims.UserID)
+		}
+
 		if err != nil {
 			c.Redirect(http.StatusFound, "/login")
 			c.Abort()
 			return
 		}
+		// Suspended users are turned away even with a valid token; the login
+		// page tells them why when they try to sign in again.
+		if user.Blocked(time.Now()) {
+			auth.ClearCookies(c, cfg)
+			c.Redirect(http.StatusFound, "/login")
+			c.Abort()
+			return
+		}
 
+		auth.TouchSession(db, session, c.ClientIP())
+		auth.SetUser(c, user)
 		c.Set("userID", claims.UserID)
+		c.Set("sessionID", claims.SessionID)
+		c.Set("mfa", claims.MFA)
+		c.Set("authMethod", authMethodSession)
 		c.Next()
 	}
 }
+
+func authenticatePersonalToken(c *gin.Context, db *gorm.DB, plain string) {
+	token, err := auth.ValidatePersonalToken(db, plain)
+	var user *models.User
+	if err == nil {
+		user, err = auth.LoadUser(db, token.UserID)
+	}
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or revoked token"})
+		c.Abort()
+		return
+	}
+	if user.Blocked(time.Now()) {
+		c.JSON(http.StatusForbidden, gin.H{"error": user.BlockedMessage()})
+		c.Abort()
+		return
+	}
+
+	scopes := make(map[string]bool)
+	for _, s := range token.ScopeList() {
+		scopes[s] = true
+	}
+
+	auth.SetUser(c, user)
+	c.Set("userID", user.ID)
+	c.Set("mfa", token.MFA)
+	c.Set("authMethod", authMethodToken)
+	c.Set("tokenScopes", scopes)
+	c.Next()
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MultipartMemory is how much of a multipart form is held in memory while
+// parsing; the rest of the files spill to temporary files. The router's
+// MaxMultipartMemory should match it.
+const MultipartMemory = 1 << 20
+
+// BodyLimit caps request bodies at maxBytes. Bodies that declare a larger
+// length are refused outright; reading past the cap fails with
+// *http.MaxBytesError, which is answered with 413.
+func BodyLimit(maxBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > maxBytes {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Request body too large"})
+			c.Abort()
+			return
+		}
+		if c.Request.Body != nil {
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+		}
+		c.Next()
+	}
+}
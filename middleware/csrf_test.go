@@ -0,0 +1,150 @@
+package middleware
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Jason-cqtan/simple-blog/config"
+	"github.com/gin-gonic/gin"
+)
+
+const testCSRFToken = "0123456789abcdef0123456789abcdef0123456789abcdef"
+
+func newCSRFRouter(maxBody int64) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.MaxMultipartMemory = MultipartMemory
+	router.Use(BodyLimit(maxBody))
+	router.Use(CSRF(&config.Config{}, "/exempt"))
+	ok := func(c *gin.Context) { c.String(http.StatusOK, "ok") }
+	router.GET("/form", ok)
+	router.POST("/form", ok)
+	router.POST("/exempt", ok)
+	return router
+}
+
+func multipartBody(t *testing.T, token string, fileSize int) (*bytes.Buffer, string) {
+	t.Helper()
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	if token != "" {
+		if err := w.WriteField(CSRFField, token); err != nil {
+			t.Fatal(err)
+		}
+	}
+	part, err := w.CreateFormFile("avatar", "a.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := part.Write(bytes.Repeat([]byte{'x'}, fileSize)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return body, w.FormDataContentType()
+}
+
+func TestCSRF(t *testing.T) {
+	form := func(token string) (*strings.Reader, string) {
+		return strings.NewReader(url.Values{CSRFField: {token}}.Encode()), "application/x-www-form-urlencoded"
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		cookie string
+		header map[string]string
+		body   func(t *testing.T) (*http.Request, error)
+		want   int
+	}{
+		{name: "safe method", method: http.MethodGet, path: "/form", want: http.StatusOK},
+		{name: "no cookie", method: http.MethodPost, path: "/form", header: map[string]string{csrfHeader: testCSRFToken}, want: http.StatusForbidden},
+		{name: "header", method: http.MethodPost, path: "/form", cookie: testCSRFToken, header: map[string]string{csrfHeader: testCSRFToken}, want: http.StatusOK},
+		{name: "wrong header", method: http.MethodPost, path: "/form", cookie: testCSRFToken, header: map[string]string{csrfHeader: strings.Repeat("z", 48)}, want: http.StatusForbidden},
+		{name: "bearer exempt", method: http.MethodPost, path: "/form", header: map[string]string{"Authorization": "Bearer abc"}, want: http.StatusOK},
+		{name: "exempt path", method: http.MethodPost, path: "/exempt", want: http.StatusOK},
+		{name: "urlencoded field", method: http.MethodPost, path: "/form", cookie: testCSRFToken, body: func(t *testing.T) (*http.Request, error) {
+			body, ct := form(testCSRFToken)
+			req := httptest.NewRequest(http.MethodPost, "/form", body)
+			req.Header.Set("Content-Type", ct)
+			return req, nil
+		}, want: http.StatusOK},
+		{name: "multipart field", method: http.MethodPost, path: "/form", cookie: testCSRFToken, body: func(t *testing.T) (*http.Request, error) {
+			body, ct := multipartBody(t, testCSRFToken, 100)
+			req := httptest.NewRequest(http.MethodPost, "/form", body)
+			req.Header.Set("Content-Type", ct)
+			return req, nil
+		}, want: http.StatusOK},
+		{name: "json body is not parsed", method: http.MethodPost, path: "/form", cookie: testCSRFToken, body: func(t *testing.T) (*http.Request, error) {
+			req := httptest.NewRequest(http.MethodPost, "/form", strings.NewReader(`{"csrf_token":"`+testCSRFToken+`"}`))
+			req.Header.Set("Content-Type", "application/json")
+			return req, nil
+		}, want: http.StatusForbidden},
+		{name: "declared length too large", method: http.MethodPost, path: "/form", cookie: testCSRFToken, body: func(t *testing.T) (*http.Request, error) {
+			body, ct := multipartBody(t, testCSRFToken, 8<<10)
+			req := httptest.NewRequest(http.MethodPost, "/form", body)
+			req.Header.Set("Content-Type", ct)
+			return req, nil
+		}, want: http.StatusRequestEntityTooLarge},
+		{name: "streamed body too large", method: http.MethodPost, path: "/form", cookie: testCSRFToken, body: func(t *testing.T) (*http.Request, error) {
+			body, ct := multipartBody(t, testCSRFToken, 8<<10)
+			req := httptest.NewRequest(http.MethodPost, "/form", body)
+			req.ContentLength = -1
+			req.Header.Set("Content-Type", ct)
+			return req, nil
+		}, want: http.StatusRequestEntityTooLarge},
+		{name: "streamed body too large with header token", method: http.MethodPost, path: "/form", cookie: testCSRFToken, header: map[string]string{csrfHeader: testCSRFToken}, body: func(t *testing.T) (*http.Request, error) {
+			body, ct := multipartBody(t, "", 8<<10)
+			req := httptest.NewRequest(http.MethodPost, "/form", body)
+			req.ContentLength = -1
+			req.Header.Set("Content-Type", ct)
+			return req, nil
+		}, want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.body != nil {
+				var err error
+				if req, err = tt.body(t); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: CSRFCookie, Value: tt.cookie})
+			}
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+
+			rec := httptest.NewRecorder()
+			newCSRFRouter(4<<10).ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d (%s)", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestCSRFIssuesCookie(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newCSRFRouter(4<<10).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/form", nil))
+
+	var token string
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == CSRFCookie {
+			token = c.Value
+		}
+	}
+	if len(token) < 32 {
+		t.Fatalf("csrf cookie = %q, want a fresh token", token)
+	}
+}
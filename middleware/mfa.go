@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireMFA rejects sessions that did not pass two-factor authentication.
+// It must run after JWTAuthMiddleware.
+func RequireMFA() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !c.GetBool("mfa") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication required"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireMFAForRoles sends users whose role is listed in roles to the 2FA
+// settings page until they sign in with a second factor. Other roles pass.
+func RequireMFAForRoles(roles []string) gin.HandlerFunc {
+	required := make(map[string]bool, len(roles))
+	for _, r := range roles {
+		required[r] = true
+	}
+	return func(c *gin.Context) {
+		if required[c.GetString("role")] && !c.GetBool("mfa") {
+			c.Redirect(http.StatusFound, "/profile/2fa")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/Jason-cqtan/simple-blog/auth"
+	"github.com/gin-gonic/gin"
+)
+
+// RequirePermission rejects requests from users whose role lacks any of the
+// given permissions. It must run after JWTAuthMiddleware.
+func RequirePermission(perms ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, perm := range perms {
+			if !auth.Can(c, perm) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireScope rejects personal access token requests whose token was not
+// granted scope. Browser sessions are not limited by scopes. It must run
+// after JWTAuthMiddleware.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("authMethod") == authMethodToken {
+			scopes, _ := c.Get("tokenScopes")
+			if granted, _ := scopes.(map[string]bool); !granted[scope] {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Token lacks the " + scope + " scope"})
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// RequireSession rejects personal access token requests, for account
+// management routes that only a signed-in user may use.
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("authMethod") != authMethodSession {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This action requires signing in"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/Jason-cqtan/simple-blog/config"
+	"github.com/gin-gonic/gin"
+)
+
+// RequireVerifiedEmail rejects users who have not confirmed their email
+// address, when cfg.RequireEmailVerification is enabled. It must run after
+// JWTAuthMiddleware.
+func RequireVerifiedEmail(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if cfg.RequireEmailVerification && !c.GetBool("emailVerified") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Please verify your email address first"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,199 @@
+package models
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCategoryNameEmpty = errors.New("category name must contain letters or digits")
+	ErrCategoryExists    = errors.New("another category already has that name")
+	ErrCategoryParent    = errors.New("a category cannot be placed under itself or its subcategories")
+)
+
+// Category groups posts. Categories form a tree through ParentID; siblings
+// are shown by Position, then name.
+type Category struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	ParentID    *uint     `gorm:"index" json:"parent_id,omitempty"`
+	Name        string    `gorm:"not null;size:100" json:"name"`
+	Slug        string    `gorm:"uniqueIndex;not null;size:100" json:"slug"`
+	Description string    `gorm:"size:500" json:"description"`
+	Position    int       `gorm:"not null;default:0" json:"position"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// URL is the category's archive page.
+func (c *Category) URL() string {
+	return "/categories/" + c.Slug
+}
+
+// HasParent reports whether category id is the category's parent.
+func (c *Category) HasParent(id uint) bool {
+	return c.ParentID != nil && *c.ParentID == id
+}
+
+// CategoryNode is a category at Depth levels below the top of the tree.
+type CategoryNode struct {
+	Category
+	Depth int
+}
+
+// Indent is the category's name indented by its depth, for <select>
+// options.
+func (n CategoryNode) Indent() string {
+	return strings.Repeat("— ", n.Depth) + n.Name
+}
+
+// CategoryTree lists all categories depth first, children right after their
+// parent.
+func CategoryTree(tx *gorm.DB) ([]CategoryNode, error) {
+	var all []Category
+	if err := tx.Order("position, name").Find(&all).Error; err != nil {
+		return nil, err
+	}
+
+	children := make(map[uint][]Category)
+	var roots []Category
+	ids := make(map[uint]bool, len(all))
+	for _, c := range all {
+		ids[c.ID] = true
+	}
+	for _, c := range all {
+		// A category whose parent is missing is shown at the top.
+		if c.ParentID == nil || !ids[*c.ParentID] {
+			roots = append(roots, c)
+		} else {
+			children[*c.ParentID] = append(children[*c.ParentID], c)
+		}
+	}
+
+	var tree []CategoryNode
+	var walk func(cats []Category, depth int)
+	walk = func(cats []Category, depth int) {
+		for _, c := range cats {
+			tree = append(tree, CategoryNode{Category: c, Depth: depth})
+			walk(children[c.ID], depth+1)
+		}
+	}
+	walk(roots, 0)
+	return tree, nil
+}
+
+// CategorySubtreeIDs returns the ID of category id and of all categories
+// below it.
+func CategorySubtreeIDs(tx *gorm.DB, id uint) ([]uint, error) {
+	var all []Category
+	if err := tx.Select("id, parent_id").Find(&all).Error; err != nil {
+		return nil, err
+	}
+	children := make(map[uint][]uint)
+	for _, c := range all {
+		if c.ParentID != nil {
+			children[*c.ParentID] = append(children[*c.ParentID], c.ID)
+		}
+	}
+
+	ids := []uint{id}
+	seen := map[uint]bool{id: true}
+	for i := 0; i < len(ids); i++ {
+		for _, child := range children[ids[i]] {
+			if !seen[child] {
+				seen[child] = true
+				ids = append(ids, child)
+			}
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+// CategoryPath returns the categories from the top of the tree down to and
+// including category id, for breadcrumbs.
+func CategoryPath(tx *gorm.DB, id uint) ([]Category, error) {
+	var path []Category
+	seen := make(map[uint]bool)
+	for next := &id; next != nil && !seen[*next]; {
+		seen[*next] = true
+		var c Category
+		if err := tx.First(&c, *next).Error; err != nil {
+			return nil, err
+		}
+		path = append([]Category{c}, path...)
+		next = c.ParentID
+	}
+	return path, nil
+}
+
+// FindOrCreateCategory returns the category named name, creating it at the
+// top of the tree if there is none. Categories are told apart by slug.
+func FindOrCreateCategory(tx *gorm.DB, name string) (*Category, error) {
+	name = strings.Join(strings.Fields(name), " ")
+	slug := Slugify(name)
+	if slug == "" {
+		return nil, ErrCategoryNameEmpty
+	}
+	c := &Category{}
+	err := tx.Where(Category{Slug: slug}).Attrs(Category{Name: name}).FirstOrCreate(c).Error
+	return c, err
+}
+
+// SaveCategory validates c, gives it the slug of its name and creates or
+// updates it. The parent must exist and must not be c or one of its
+// subcategories.
+func SaveCategory(tx *gorm.DB, c *Category) error {
+	c.Name = strings.Join(strings.Fields(c.Name), " ")
+	c.Slug = Slugify(c.Name)
+	if c.Slug == "" {
+		return ErrCategoryNameEmpty
+	}
+
+	var taken int64
+	if err := tx.Model(&Category{}).Where("slug = ? AND id <> ?", c.Slug, c.ID).Count(&taken).Error; err != nil {
+		return err
+	}
+	if taken > 0 {
+		return ErrCategoryExists
+	}
+
+	if c.ParentID != nil {
+		if err := tx.First(&Category{}, *c.ParentID).Error; err != nil {
+			return ErrCategoryParent
+		}
+		if c.ID != 0 {
+			subtree, err := CategorySubtreeIDs(tx, c.ID)
+			if err != nil {
+				return err
+			}
+			for _, id := range subtree {
+				if id == *c.ParentID {
+					return ErrCategoryParent
+				}
+			}
+		}
+	}
+
+	if c.ID == 0 {
+		return tx.Create(c).Error
+	}
+	return tx.Model(&Category{ID: c.ID}).Select("parent_id", "name", "slug", "description", "position").Updates(c).Error
+}
+
+// DeleteCategory deletes category c. Its subcategories and posts move up to
+// its parent, or to the top of the tree and no category.
+func DeleteCategory(db *gorm.DB, c *Category) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Category{}).Where("parent_id = ?", c.ID).Update("parent_id", c.ParentID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&Post{}).Where("category_id = ?", c.ID).UpdateColumn("category_id", c.ParentID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&Category{}, c.ID).Error
+	})
+}
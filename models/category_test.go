@@ -0,0 +1,142 @@
+package models
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Jason-cqtan/simple-blog/internal/testdb"
+	"gorm.io/gorm"
+)
+
+// createCategories creates a category per [name, parent name] pair, in
+// order, and returns them by name.
+func createCategories(t *testing.T, db *gorm.DB, pairs ...[2]string) map[string]*Category {
+	t.Helper()
+	byName := make(map[string]*Category)
+	for _, p := range pairs {
+		c := &Category{Name: p[0]}
+		if parent := byName[p[1]]; parent != nil {
+			c.ParentID = &parent.ID
+		}
+		if err := SaveCategory(db, c); err != nil {
+			t.Fatalf("create %s: %v", p[0], err)
+		}
+		byName[p[0]] = c
+	}
+	return byName
+}
+
+func categoryNames(cats []Category) []string {
+	var names []string
+	for _, c := range cats {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+func TestCategoryPath(t *testing.T) {
+	db := testdb.Open(t, &Category{})
+	cats := createCategories(t, db,
+		[2]string{"Tech", ""},
+		[2]string{"Go", "Tech"},
+		[2]string{"Generics", "Go"},
+		[2]string{"Life", ""},
+		[2]string{"A", ""},
+		[2]string{"B", "A"},
+		[2]string{"C", "B"},
+	)
+	// SaveCategory refuses cycles, so make them directly as a corrupt
+	// database would have them: A -> C -> B -> A, and a self-loop.
+	db.Model(&Category{}).Where("id = ?", cats["A"].ID).Update("parent_id", cats["C"].ID)
+	db.Model(&Category{}).Where("id = ?", cats["Life"].ID).Update("parent_id", cats["Life"].ID)
+
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"Tech", []string{"Tech"}},
+		{"Go", []string{"Tech", "Go"}},
+		{"Generics", []string{"Tech", "Go", "Generics"}},
+		{"Life", []string{"Life"}},
+		{"C", []string{"A", "B", "C"}},
+		{"A", []string{"B", "C", "A"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, err := CategoryPath(db, cats[tt.name].ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := categoryNames(path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CategoryPath = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := CategoryPath(db, 999); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("missing category: err = %v, want ErrRecordNotFound", err)
+	}
+}
+
+func TestCategorySubtreeIDs(t *testing.T) {
+	db := testdb.Open(t, &Category{})
+	cats := createCategories(t, db,
+		[2]string{"Tech", ""},
+		[2]string{"Go", "Tech"},
+		[2]string{"Generics", "Go"},
+		[2]string{"Rust", "Tech"},
+		[2]string{"Life", ""},
+	)
+
+	got, err := CategorySubtreeIDs(db, cats["Tech"].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []uint{cats["Tech"].ID, cats["Go"].ID, cats["Generics"].ID, cats["Rust"].ID}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("subtree of Tech = %v, want %v", got, want)
+	}
+
+	// A cycle must not loop forever.
+	db.Model(&Category{}).Where("id = ?", cats["Tech"].ID).Update("parent_id", cats["Generics"].ID)
+	if got, err := CategorySubtreeIDs(db, cats["Go"].ID); err != nil || len(got) != 4 {
+		t.Errorf("subtree with a cycle = %v, %v; want 4 categories", got, err)
+	}
+}
+
+func TestSaveCategory(t *testing.T) {
+	db := testdb.Open(t, &Category{})
+	cats := createCategories(t, db,
+		[2]string{"Tech", ""},
+		[2]string{"Go", "Tech"},
+		[2]string{"Generics", "Go"},
+	)
+	missing := uint(999)
+
+	tests := []struct {
+		name   string
+		c      Category
+		parent string
+		err    error
+	}{
+		{name: "new top-level", c: Category{Name: "  Life  "}},
+		{name: "blank name", c: Category{Name: "!!"}, err: ErrCategoryNameEmpty},
+		{name: "same slug", c: Category{Name: "TECH"}, err: ErrCategoryExists},
+		{name: "under itself", c: *cats["Go"], parent: "Go", err: ErrCategoryParent},
+		{name: "under its subcategory", c: *cats["Tech"], parent: "Generics", err: ErrCategoryParent},
+		{name: "missing parent", c: Category{Name: "Orphan", ParentID: &missing}, err: ErrCategoryParent},
+		{name: "move up", c: *cats["Generics"], parent: "Tech"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.c
+			if tt.parent != "" {
+				c.ParentID = &cats[tt.parent].ID
+			}
+			if err := SaveCategory(db, &c); !errors.Is(err, tt.err) {
+				t.Errorf("SaveCategory = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
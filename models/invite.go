@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Invite lets people register while registration is invite-only. Only the
+// SHA-256 hash of the code is stored; Prefix is kept so the creator can tell
+// their invites apart.
+type Invite struct {
+	ID          uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	CreatedByID uint       `gorm:"not null;index" json:"created_by_id"`
+	CreatedBy   User       `gorm:"foreignKey:CreatedByID" json:"-"`
+	CodeHash    string     `gorm:"uniqueIndex;not null;size:64" json:"-"`
+	Prefix      string     `gorm:"not null;size:16" json:"prefix"`
+	Note        string     `gorm:"size:255" json:"note"`
+	MaxUses     int        `gorm:"not null;default:1" json:"max_uses"`
+	Uses        int        `gorm:"not null;default:0" json:"uses"`
+	ExpiresAt   time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// Usable reports whether the invite can still be redeemed.
+func (i *Invite) Usable() bool {
+	return i.RevokedAt == nil && i.Uses < i.MaxUses && time.Now().Before(i.ExpiresAt)
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// LoginAttempt is the failure counter for one throttling key, used by the
+// database-backed throttle store. The times are nil until the first failure
+// and lockout; MySQL in strict mode rejects zero dates.
+type LoginAttempt struct {
+	Key           string     `gorm:"primaryKey;size:191" json:"key"`
+	Failures      int        `gorm:"not null;default:0" json:"failures"`
+	LastFailureAt *time.Time `json:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until"`
+}
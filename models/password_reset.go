@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// PasswordReset is a single-use, expiring password reset token. Only the
+// SHA-256 hash of the token is stored.
+type PasswordReset struct {
+	ID        uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	User      User       `gorm:"foreignKey:UserID" json:"-"`
+	TokenHash string     `gorm:"uniqueIndex;not null;size:64" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Usable reports whether the token has neither been used nor expired.
+func (r *PasswordReset) Usable() bool {
+	return r.UsedAt == nil && time.Now().Before(r.ExpiresAt)
+}
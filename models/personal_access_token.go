@@ -0,0 +1,47 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Scopes a personal access token can be granted.
+const (
+	ScopePostsRead     = "posts:read"
+	ScopePostsWrite    = "posts:write"
+	ScopeCommentsWrite = "comments:write"
+)
+
+// AllScopes lists every scope in display order.
+var AllScopes = []string{ScopePostsRead, ScopePostsWrite, ScopeCommentsWrite}
+
+// PersonalAccessToken is a long-lived API token for scripts and CI. Only the
+// SHA-256 hash of the token is stored; Prefix is kept so users can tell
+// their tokens apart.
+type PersonalAccessToken struct {
+	ID         uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID     uint       `gorm:"not null;index" json:"user_id"`
+	User       User       `gorm:"foreignKey:UserID" json:"-"`
+	Name       string     `gorm:"not null;size:100" json:"name"`
+	TokenHash  string     `gorm:"uniqueIndex;not null;size:64" json:"-"`
+	Prefix     string     `gorm:"not null;size:16" json:"prefix"`
+	Scopes     string     `gorm:"not null;size:255" json:"scopes"`
+	MFA        bool       `gorm:"not null;default:false" json:"mfa"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// ScopeList returns the granted scopes.
+func (t *PersonalAccessToken) ScopeList() []string {
+	if t.Scopes == "" {
+		return nil
+	}
+	return strings.Split(t.Scopes, ",")
+}
+
+// Active reports whether the token can still be used.
+func (t *PersonalAccessToken) Active() bool {
+	return t.RevokedAt == nil && (t.ExpiresAt == nil || time.Now().Before(*t.ExpiresAt))
+}
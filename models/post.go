@@ -1,17 +1,143 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Post states. Only published posts appear on public pages; the others are
+// visible to their author through the preview page.
+const (
+	PostStatusDraft     = "draft"
+	PostStatusScheduled = "scheduled"
+	PostStatusPublished = "published"
+	PostStatusArchived  = "archived"
+)
 
 type Post struct {
-	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
-	Title     string    `gorm:"not null;size:255" json:"title"`
-	Content   string    `gorm:"type:text" json:"content"`
-	Excerpt   string    `gorm:"size:500" json:"excerpt"`
-	AuthorID  uint      `gorm:"not null" json:"author_id"`
-	Author    User      `gorm:"foreignKey:AuthorID" json:"author"`
-	Category  string    `gorm:"size:100" json:"category"`
-	Tags      string    `gorm:"size:255" json:"tags"`
-	Published bool      `gorm:"default:true" json:"published"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID    uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	Title string `gorm:"not null;size:255" json:"title"`
+	// Slug names the post in its permalink; earlier slugs are kept as
+	// PostSlug rows.
+	Slug    string `gorm:"uniqueIndex;size:200" json:"slug"`
+	Content string `gorm:"type:text" json:"content"`
+	// ContentHTML caches Content rendered from Markdown; it is current while
+	// ContentHTMLKey matches markdown.Key(Content). Rendered HTML can outgrow
+	// MySQL's 64 KB TEXT, so the column is left unsized: LONGTEXT on MySQL,
+	// TEXT elsewhere.
+	ContentHTML    string    `json:"-"`
+	ContentHTMLKey string    `gorm:"size:64" json:"-"`
+	Excerpt        string    `gorm:"size:500" json:"excerpt"`
+	AuthorID       uint      `gorm:"not null" json:"author_id"`
+	Author         User      `gorm:"foreignKey:AuthorID" json:"author"`
+	CategoryID     *uint     `gorm:"index" json:"category_id,omitempty"`
+	Category       *Category `gorm:"foreignKey:CategoryID" json:"category,omitempty"`
+	Tags           []Tag     `gorm:"many2many:post_tags" json:"tags"`
+	Status         string    `gorm:"size:20;not null;default:published;index" json:"status"`
+	// Published mirrors Status == PostStatusPublished; public queries filter
+	// on it.
+	Published bool `gorm:"default:false" json:"published"`
+	// PublishAt is when a scheduled post goes live.
+	PublishAt *time.Time `gorm:"index" json:"publish_at,omitempty"`
+	// PublishedAt is when the post first went live; it dates the permalink.
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// URL is the post's permalink, /YYYY/MM/slug, dated by the month it was
+// first published, or created if it never was.
+func (p *Post) URL() string {
+	if p.Slug == "" {
+		return fmt.Sprintf("/posts/%d", p.ID)
+	}
+	date := p.Date().UTC()
+	return fmt.Sprintf("/%04d/%02d/%s", date.Year(), date.Month(), p.Slug)
+}
+
+// CategoryName is the name of the post's category, or "" without one;
+// Category must be loaded.
+func (p *Post) CategoryName() string {
+	if p.Category == nil {
+		return ""
+	}
+	return p.Category.Name
+}
+
+// InCategory reports whether the post is filed under category id itself.
+func (p *Post) InCategory(id uint) bool {
+	return p.CategoryID != nil && *p.CategoryID == id
+}
+
+// TagNames joins the post's tag names into the comma-separated form the post
+// editor uses.
+func (p *Post) TagNames() string {
+	names := make([]string, len(p.Tags))
+	for i, t := range p.Tags {
+		names[i] = t.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// Date is when the post went live, or was created if it never did.
+func (p *Post) Date() time.Time {
+	if p.PublishedAt != nil {
+		return *p.PublishedAt
+	}
+	return p.CreatedAt
+}
+
+// SetStatus moves the post to status, keeping Published, PublishAt and
+// PublishedAt in step. publishAt only applies to scheduled posts.
+func (p *Post) SetStatus(status string, publishAt *time.Time, now time.Time) {
+	p.Status = status
+	p.Published = status == PostStatusPublished
+	p.PublishAt = nil
+	switch status {
+	case PostStatusScheduled:
+		p.PublishAt = publishAt
+	case PostStatusPublished:
+		if p.PublishedAt == nil {
+			p.PublishedAt = &now
+		}
+	}
+}
+
+// BeforeCreate fills in the status of posts created with only the Published
+// flag, and gives posts created without a slug one made from the title.
+func (p *Post) BeforeCreate(tx *gorm.DB) error {
+	if p.Status == "" {
+		status := PostStatusDraft
+		if p.Published {
+			status = PostStatusPublished
+		}
+		p.SetStatus(status, nil, time.Now())
+	}
+
+	if p.Slug != "" {
+		return nil
+	}
+	slug, err := UniquePostSlug(tx, 0, p.Title)
+	if err != nil {
+		return err
+	}
+	p.Slug = slug
+	return nil
+}
+
+// AfterSave adds the current slug to the post's slug history.
+func (p *Post) AfterSave(tx *gorm.DB) error {
+	if p.ID == 0 || p.Slug == "" {
+		return nil
+	}
+	return tx.Where(PostSlug{Slug: p.Slug}).Attrs(PostSlug{PostID: p.ID}).FirstOrCreate(&PostSlug{}).Error
+}
+
+// AfterCreate records the new post as its first revision, credited to the
+// author.
+func (p *Post) AfterCreate(tx *gorm.DB) error {
+	return RecordPostRevision(tx, p, p.AuthorID, "")
 }
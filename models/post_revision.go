@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PostRevision is a snapshot of a post's text, recorded each time it is
+// created, edited or restored. Revisions are numbered from 1 per post.
+type PostRevision struct {
+	ID      uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	PostID  uint   `gorm:"not null;uniqueIndex:idx_post_revisions_number" json:"post_id"`
+	Number  int    `gorm:"not null;uniqueIndex:idx_post_revisions_number" json:"number"`
+	Title   string `gorm:"not null;size:255" json:"title"`
+	Content string `gorm:"type:text" json:"content"`
+	Excerpt string `gorm:"size:500" json:"excerpt"`
+	// Tags joins the post's tag names; a post may have any number of tags,
+	// so the column is unsized.
+	Tags string `gorm:"type:text" json:"tags"`
+	// EditorID is who saved the revision; it is cleared when their account
+	// is deleted.
+	EditorID  *uint     `gorm:"index" json:"editor_id,omitempty"`
+	Editor    *User     `gorm:"foreignKey:EditorID" json:"-"`
+	Note      string    `gorm:"size:255" json:"note"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// EditorName is the name of who saved the revision.
+func (r *PostRevision) EditorName() string {
+	if r.Editor == nil {
+		return "Deleted user"
+	}
+	return r.Editor.Name()
+}
+
+// RecordPostRevision records the post's current title, content, excerpt and
+// tags as its next revision; post.Tags must be loaded. Nothing is recorded
+// when they are unchanged since the latest revision, such as when only the
+// status or slug changed.
+func RecordPostRevision(tx *gorm.DB, post *Post, editorID uint, note string) error {
+	var latest []PostRevision
+	if err := tx.Where("post_id = ?", post.ID).Order("number desc").Limit(1).Find(&latest).Error; err != nil {
+		return err
+	}
+
+	number := 1
+	if len(latest) > 0 {
+		last := latest[0]
+		if last.Title == post.Title && last.Content == post.Content &&
+			last.Excerpt == post.Excerpt && last.Tags == post.TagNames() {
+			return nil
+		}
+		number = last.Number + 1
+	}
+	return tx.Create(&PostRevision{
+		PostID:   post.ID,
+		Number:   number,
+		Title:    post.Title,
+		Content:  post.Content,
+		Excerpt:  post.Excerpt,
+		Tags:     post.TagNames(),
+		EditorID: &editorID,
+		Note:     note,
+	}).Error
+}
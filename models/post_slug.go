@@ -0,0 +1,74 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gosimple/slug"
+	"gorm.io/gorm"
+)
+
+// maxSlugLength keeps permalinks readable; longer titles are cut at a word
+// boundary.
+const maxSlugLength = 80
+
+// PostSlug is a slug a post has had. A post's current slug is recorded here
+// too, so a slug is never handed to another post and old links keep
+// resolving after a rename.
+type PostSlug struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	PostID    uint      `gorm:"not null;index" json:"post_id"`
+	Slug      string    `gorm:"uniqueIndex;not null;size:200" json:"slug"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Slugify turns text into a lowercase, hyphen-separated slug, transliterating
+// non-Latin scripts ("Привет, мир" becomes "privet-mir").
+func Slugify(text string) string {
+	s := slug.Make(text)
+	if len(s) > maxSlugLength {
+		s = s[:maxSlugLength]
+		if i := strings.LastIndexByte(s, '-'); i > 0 {
+			s = s[:i]
+		}
+	}
+	return strings.Trim(s, "-")
+}
+
+// UniquePostSlug returns the slug of text, with the lowest "-2", "-3", …
+// suffix needed to make it unique. Slugs the post postID has had itself are
+// available to it again; pass 0 for a new post.
+func UniquePostSlug(tx *gorm.DB, postID uint, text string) (string, error) {
+	return uniqueSlug(text, "post", func(candidate string) (bool, error) {
+		var owners []PostSlug
+		if err := tx.Where("slug = ?", candidate).Limit(1).Find(&owners).Error; err != nil {
+			return false, fmt.Errorf("look up slug %s: %w", candidate, err)
+		}
+		return len(owners) > 0 && (postID == 0 || owners[0].PostID != postID), nil
+	})
+}
+
+// uniqueSlug slugifies text, falling back to fallback when nothing is left,
+// and adds the lowest "-2", "-3", … suffix for which taken reports false.
+func uniqueSlug(text, fallback string, taken func(candidate string) (bool, error)) (string, error) {
+	base := Slugify(text)
+	if base == "" {
+		base = fallback
+	}
+
+	for n := 1; ; n++ {
+		candidate := base
+		if n > 1 {
+			candidate = base + "-" + strconv.Itoa(n)
+		}
+		inUse, err := taken(candidate)
+		if err != nil {
+			return "", err
+		}
+		if !inUse {
+			return candidate, nil
+		}
+	}
+}
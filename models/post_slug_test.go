@@ -0,0 +1,75 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Jason-cqtan/simple-blog/internal/testdb"
+)
+
+func TestSlugify(t *testing.T) {
+	long := strings.Repeat("word ", 30)
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Hello, World!", "hello-world"},
+		{"  Go   1.23 released ", "go-1-23-released"},
+		{"Привет, мир", "privet-mir"},
+		{"Crème brûlée", "creme-brulee"},
+		{"already-a-slug", "already-a-slug"},
+		{"--dashes--", "dashes"},
+		{"", ""},
+		{"!!!", ""},
+		{long, strings.TrimSuffix(strings.Repeat("word-", 16), "-")},
+	}
+	for _, tt := range tests {
+		got := Slugify(tt.text)
+		if got != tt.want {
+			t.Errorf("Slugify(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+		if len(got) > maxSlugLength {
+			t.Errorf("Slugify(%q) is %d bytes, over %d", tt.text, len(got), maxSlugLength)
+		}
+	}
+}
+
+func TestUniquePostSlug(t *testing.T) {
+	db := testdb.Open(t, &PostSlug{})
+	for _, s := range []PostSlug{
+		{PostID: 1, Slug: "hello"},
+		{PostID: 2, Slug: "hello-2"},
+		{PostID: 2, Slug: "old-name"},
+		{PostID: 3, Slug: "post"},
+	} {
+		if err := db.Create(&s).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		postID uint
+		text   string
+		want   string
+	}{
+		{name: "new post, free slug", text: "Goodbye", want: "goodbye"},
+		{name: "new post, taken slug", text: "Hello", want: "hello-3"},
+		{name: "owner keeps its slug", postID: 1, text: "Hello!", want: "hello"},
+		{name: "owner of a suffixed slug", postID: 2, text: "hello", want: "hello-2"},
+		{name: "earlier slug of the same post", postID: 2, text: "Old Name", want: "old-name"},
+		{name: "earlier slug of another post", postID: 1, text: "Old Name", want: "old-name-2"},
+		{name: "nothing to slugify", text: "???", want: "post-2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := UniquePostSlug(db, tt.postID, tt.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("UniquePostSlug(%d, %q) = %q, want %q", tt.postID, tt.text, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package models
+
+import "time"
+
+// Built-in role names.
+const (
+	RoleAdmin  = "admin"
+	RoleEditor = "editor"
+	RoleAuthor = "author"
+	RoleReader = "reader"
+)
+
+// Permission names checked by routes and handlers.
+const (
+	PermPostCreate       = "post.create"
+	PermPostEditOwn      = "post.edit.own"
+	PermPostEditAny      = "post.edit.any"
+	PermPostDeleteOwn    = "post.delete.own"
+	PermPostDeleteAny    = "post.delete.any"
+	PermCommentCreate    = "comment.create"
+	PermCommentDeleteOwn = "comment.delete.own"
+	PermCommentDeleteAny = "comment.delete.any"
+	PermUserManage       = "user.manage"
+	PermUserInvite       = "user.invite"
+)
+
+// DefaultRolePermissions is the permission set each built-in role is created
+// with. Grants can be changed in the database afterwards.
+var DefaultRolePermissions = map[string][]string{
+	RoleAdmin: {
+		PermPostCreate, PermPostEditOwn, PermPostEditAny, PermPostDeleteOwn, PermPostDeleteAny,
+		PermCommentCreate, PermCommentDeleteOwn, PermCommentDeleteAny,
+		PermUserManage, PermUserInvite,
+	},
+	RoleEditor: {
+		PermPostCreate, PermPostEditOwn, PermPostEditAny, PermPostDeleteOwn, PermPostDeleteAny,
+		PermCommentCreate, PermCommentDeleteOwn, PermCommentDeleteAny,
+		PermUserInvite,
+	},
+	RoleAuthor: {
+		PermPostCreate, PermPostEditOwn, PermPostDeleteOwn,
+		PermCommentCreate, PermCommentDeleteOwn,
+		PermUserInvite,
+	},
+	RoleReader: {
+		PermCommentCreate, PermCommentDeleteOwn,
+		PermUserInvite,
+	},
+}
+
+type Role struct {
+	ID          uint         `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name        string       `gorm:"uniqueIndex;not null;size:50" json:"name"`
+	Description string       `gorm:"size:255" json:"description"`
+	Permissions []Permission `gorm:"many2many:role_permissions" json:"permissions"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+type Permission struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name        string    `gorm:"uniqueIndex;not null;size:100" json:"name"`
+	Description string    `gorm:"size:255" json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Has reports whether the role grants the named permission.
+func (r *Role) Has(perm string) bool {
+	for _, p := range r.Permissions {
+		if p.Name == perm {
+			return true
+		}
+	}
+	return false
+}
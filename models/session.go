@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"github.com/Jason-cqtan/simple-blog/utils"
+)
+
+// Session is a server-side login session. Every access token carries the
+// session ID, so revoking the row invalidates the token immediately.
+type Session struct {
+	ID               uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID           uint       `gorm:"not null;index" json:"user_id"`
+	User             User       `gorm:"foreignKey:UserID" json:"-"`
+	RefreshTokenHash string     `gorm:"uniqueIndex;not null;size:64" json:"-"`
+	MFA              bool       `gorm:"not null;default:false" json:"mfa"`
+	UserAgent        string     `gorm:"size:255" json:"user_agent"`
+	IP               string     `gorm:"size:45" json:"ip"`
+	LastSeenAt       *time.Time `json:"last_seen_at"`
+	ExpiresAt        time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt        *time.Time `json:"revoked_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+// Active reports whether the session can still be used.
+func (s *Session) Active() bool {
+	return s.RevokedAt == nil && time.Now().Before(s.ExpiresAt)
+}
+
+// Device describes the browser and system the session was started from.
+func (s *Session) Device() string {
+	return utils.DescribeUserAgent(s.UserAgent)
+}
@@ -0,0 +1,147 @@
+package models
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// MaxTagNameLength is the longest tag name accepted, in characters.
+const MaxTagNameLength = 50
+
+var (
+	ErrTagNameTooLong = errors.New("tag names must be at most 50 characters")
+	ErrTagNameEmpty   = errors.New("tag name must not be empty")
+	ErrTagExists      = errors.New("another tag already has that name; merge them instead")
+)
+
+// Tag labels posts. Tags are told apart by name, ignoring case and extra
+// spaces, so "Go", "go" and " GO " are one tag; it keeps the spelling it was
+// first created with. Names that slugify alike, such as "C" and "C++", get
+// numbered slugs ("c", "c-2").
+type Tag struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name      string    `gorm:"not null;size:50" json:"name"`
+	Slug      string    `gorm:"uniqueIndex;not null;size:100" json:"slug"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// URL is the page listing the tag's posts.
+func (t *Tag) URL() string {
+	return "/tags/" + t.Slug
+}
+
+// OrderTags sorts preloaded tags alphabetically, e.g.
+// Preload("Tags", models.OrderTags).
+func OrderTags(db *gorm.DB) *gorm.DB {
+	return db.Order("tags.slug")
+}
+
+// FindOrCreateTags returns the tags named in input, a comma-separated list,
+// creating those that do not exist yet. Blank entries and repeats are
+// dropped, and the tags are sorted by slug like OrderTags.
+func FindOrCreateTags(tx *gorm.DB, input string) ([]Tag, error) {
+	var tags []Tag
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(input, ",") {
+		name = normalizeTagName(name)
+		if name == "" || seen[strings.ToLower(name)] {
+			continue
+		}
+		if utf8.RuneCountInString(name) > MaxTagNameLength {
+			return nil, ErrTagNameTooLong
+		}
+		seen[strings.ToLower(name)] = true
+
+		var found []Tag
+		if err := tx.Where("LOWER(name) = LOWER(?)", name).Limit(1).Find(&found).Error; err != nil {
+			return nil, err
+		}
+		if len(found) > 0 {
+			tags = append(tags, found[0])
+			continue
+		}
+
+		slug, err := UniqueTagSlug(tx, 0, name)
+		if err != nil {
+			return nil, err
+		}
+		tag := Tag{Name: name, Slug: slug}
+		if err := tx.Create(&tag).Error; err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Slug < tags[j].Slug })
+	return tags, nil
+}
+
+// UniqueTagSlug returns the slug of name, numbered like UniquePostSlug if
+// another tag has it. The tag tagID may keep its own slug; pass 0 for a new
+// tag.
+func UniqueTagSlug(tx *gorm.DB, tagID uint, name string) (string, error) {
+	return uniqueSlug(name, "tag", func(candidate string) (bool, error) {
+		var taken int64
+		err := tx.Model(&Tag{}).Where("slug = ? AND id <> ?", candidate, tagID).Count(&taken).Error
+		return taken > 0, err
+	})
+}
+
+// FindTagByName looks a tag up by name, ignoring case and extra spaces.
+func FindTagByName(tx *gorm.DB, name string) (*Tag, error) {
+	var tag Tag
+	if err := tx.Where("LOWER(name) = LOWER(?)", normalizeTagName(name)).First(&tag).Error; err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// RenameTag gives tag a new name and the slug that goes with it. Renaming
+// to the name of another tag fails with ErrTagExists.
+func RenameTag(tx *gorm.DB, tag *Tag, name string) error {
+	name = normalizeTagName(name)
+	if name == "" {
+		return ErrTagNameEmpty
+	}
+	if utf8.RuneCountInString(name) > MaxTagNameLength {
+		return ErrTagNameTooLong
+	}
+
+	var taken int64
+	if err := tx.Model(&Tag{}).Where("LOWER(name) = LOWER(?) AND id <> ?", name, tag.ID).Count(&taken).Error; err != nil {
+		return err
+	}
+	if taken > 0 {
+		return ErrTagExists
+	}
+	slug, err := UniqueTagSlug(tx, tag.ID, name)
+	if err != nil {
+		return err
+	}
+	tag.Name, tag.Slug = name, slug
+	return tx.Model(&Tag{ID: tag.ID}).Updates(map[string]interface{}{"name": name, "slug": slug}).Error
+}
+
+func normalizeTagName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// MergeTags moves the posts tagged from over to into and deletes from.
+func MergeTags(db *gorm.DB, from, into *Tag) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`INSERT INTO post_tags (post_id, tag_id)
+			SELECT post_id, ? FROM post_tags
+			WHERE tag_id = ? AND post_id NOT IN (SELECT post_id FROM post_tags WHERE tag_id = ?)`,
+			into.ID, from.ID, into.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM post_tags WHERE tag_id = ?", from.ID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&Tag{}, from.ID).Error
+	})
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Jason-cqtan/simple-blog/internal/testdb"
+)
+
+func TestFindOrCreateTags(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing []string
+		input    string
+		want     []string // "name/slug", in slug order
+		err      error
+	}{
+		{name: "empty", input: " , ,", want: nil},
+		{name: "case and spaces", input: "Go, go , GO", want: []string{"Go/go"}},
+		{name: "same slug, different names", input: "Go, go , C++, C#, C", want: []string{"C++/c", "C#/c-2", "C/c-3", "Go/go"}},
+		{name: "existing tag keeps its spelling", existing: []string{"Golang"}, input: "golang", want: []string{"Golang/golang"}},
+		{name: "existing slug is numbered", existing: []string{"C"}, input: "C++", want: []string{"C++/c-2"}},
+		{name: "no letters or digits", input: "#, +", want: []string{"#/tag", "+/tag-2"}},
+		{name: "too long", input: strings.Repeat("é", MaxTagNameLength+1), err: ErrTagNameTooLong},
+		{name: "longest", input: strings.Repeat("é", MaxTagNameLength), want: []string{strings.Repeat("é", MaxTagNameLength) + "/" + strings.Repeat("e", MaxTagNameLength)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testdb.Open(t, &Tag{})
+			for _, name := range tt.existing {
+				if _, err := FindOrCreateTags(db, name); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			tags, err := FindOrCreateTags(db, tt.input)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			var got []string
+			for _, tag := range tags {
+				got = append(got, tag.Name+"/"+tag.Slug)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("tags = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenameTag(t *testing.T) {
+	db := testdb.Open(t, &Tag{})
+	tags, err := FindOrCreateTags(db, "C, Go, Rust")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, golang := &tags[0], &tags[1]
+
+	if err := RenameTag(db, golang, " c "); !errors.Is(err, ErrTagExists) {
+		t.Errorf("rename to another tag's name: err = %v, want ErrTagExists", err)
+	}
+	if err := RenameTag(db, golang, "  "); !errors.Is(err, ErrTagNameEmpty) {
+		t.Errorf("rename to blank: err = %v, want ErrTagNameEmpty", err)
+	}
+	if err := RenameTag(db, golang, "C++"); err != nil || golang.Slug != "c-2" {
+		t.Errorf("rename to C++: slug %q, err %v; want c-2", golang.Slug, err)
+	}
+	if err := RenameTag(db, c, "c"); err != nil || c.Name != "c" || c.Slug != "c" {
+		t.Errorf("rename to own name: %q/%q, err %v; want c/c", c.Name, c.Slug, err)
+	}
+
+	found, err := FindTagByName(db, "c++")
+	if err != nil || found.ID != golang.ID {
+		t.Errorf("FindTagByName(c++) = %v, %v; want tag %d", found, err, golang.ID)
+	}
+}
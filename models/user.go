@@ -1,31 +1,136 @@
 package models
 
 import (
+	"strings"
 	"time"
 
-	"golang.org/x/crypto/bcrypt"
+	"github.com/Jason-cqtan/simple-blog/utils"
+	"gorm.io/gorm"
 )
 
+// The placeholder account that content of anonymized, deleted users is
+// reassigned to. DeletedUserEmail is not a valid address, so nobody can
+// register it.
+const (
+	DeletedUsername  = "deleted-user"
+	DeletedUserEmail = "deleted-user"
+)
+
+// Account states set by administrators. Suspended and banned accounts are
+// refused sign-in and every request until the status expires or is lifted.
+const (
+	UserStatusActive    = "active"
+	UserStatusSuspended = "suspended"
+	UserStatusBanned    = "banned"
+)
+
+// IsReservedUsername reports whether name is kept for internal accounts.
+func IsReservedUsername(name string) bool {
+	return strings.EqualFold(name, DeletedUsername)
+}
+
 type User struct {
-	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
-	Username  string    `gorm:"uniqueIndex;not null;size:100" json:"username"`
-	Email     string    `gorm:"uniqueIndex;not null;size:255" json:"email"`
-	Password  string    `gorm:"not null" json:"-"`
-	Bio       string    `gorm:"size:500" json:"bio"`
+	ID              uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	Username        string     `gorm:"uniqueIndex;not null;size:100" json:"username"`
+	Email           string     `gorm:"uniqueIndex;not null;size:255" json:"email"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	Password        string     `gorm:"not null" json:"-"`
+	DisplayName     string     `gorm:"size:100" json:"display_name"`
+	Bio             string     `gorm:"size:500" json:"bio"`
+	Website         string     `gorm:"size:255" json:"website"`
+	Avatar          string     `gorm:"size:255" json:"-"`
+	RoleID          uint       `gorm:"index" json:"role_id"`
+	Role            Role       `gorm:"foreignKey:RoleID" json:"role"`
+	TOTPSecret      string     `gorm:"size:64" json:"-"`
+	TOTPEnabled     bool       `gorm:"not null;default:false" json:"totp_enabled"`
+	TOTPLastStep    int64      `gorm:"not null;default:0" json:"-"`
+	Status          string     `gorm:"size:20;not null;default:active;index" json:"status"`
+	StatusReason    string     `gorm:"size:255" json:"status_reason,omitempty"`
+	StatusUntil     *time.Time `json:"status_until,omitempty"`
+	// PasswordResetRequired blocks password sign-in until the user sets a
+	// new password through a reset link.
+	PasswordResetRequired bool `gorm:"not null;default:false" json:"password_reset_required"`
+	// InviteID records the invite the account registered with, if any.
+	InviteID  *uint     `gorm:"index" json:"invite_id,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
-func (u *User) HashPassword(plain string) error {
-	hashed, err := bcrypt.GenerateFromPassword([]byte(plain), bcrypt.DefaultCost)
+// EmailVerified reports whether the user has confirmed their current address.
+func (u *User) EmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// Blocked reports whether the account is suspended or banned at now.
+// A status with an expiry stops applying once it has passed.
+func (u *User) Blocked(now time.Time) bool {
+	if u.Status == "" || u.Status == UserStatusActive {
+		return false
+	}
+	return u.StatusUntil == nil || now.Before(*u.StatusUntil)
+}
+
+// BlockedMessage explains a block to its user.
+func (u *User) BlockedMessage() string {
+	msg := "Your account has been " + u.Status
+	if u.StatusUntil != nil {
+		msg += " until " + u.StatusUntil.UTC().Format("2006-01-02 15:04 UTC")
+	}
+	if u.StatusReason != "" {
+		msg += ": " + u.StatusReason
+	}
+	return msg + "."
+}
+
+// Name is the name shown next to the user's content.
+func (u *User) Name() string {
+	if u.DisplayName != "" {
+		return u.DisplayName
+	}
+	return u.Username
+}
+
+// AvatarURL is the public path of the user's avatar, or empty when none was
+// uploaded.
+func (u *User) AvatarURL() string {
+	if u.Avatar == "" {
+		return ""
+	}
+	return "/media/" + u.Avatar
+}
+
+// BeforeCreate assigns the author role to users created without one.
+func (u *User) BeforeCreate(tx *gorm.DB) error {
+	if u.RoleID != 0 {
+		return nil
+	}
+	var role Role
+	if err := tx.Where("name = ?", RoleAuthor).First(&role).Error; err != nil {
+		return err
+	}
+	u.RoleID = role.ID
+	return nil
+}
+
+// HashPassword stores plain hashed with hasher.
+func (u *User) HashPassword(hasher utils.PasswordHasher, plain string) error {
+	hashed, err := hasher.Hash(plain)
 	if err != nil {
 		return err
 	}
-	u.Password = string(hashed)
+	u.Password = hashed
 	return nil
 }
 
+// HasPassword reports whether the user can sign in with a password.
+// Accounts created through single sign-on have none until the user sets one
+// with a password reset link.
+func (u *User) HasPassword() bool {
+	return u.Password != ""
+}
+
+// CheckPassword reports whether plain is the user's password, whichever
+// algorithm its stored hash was made with.
 func (u *User) CheckPassword(plain string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(plain))
-	return err == nil
+	return u.HasPassword() && utils.VerifyPassword(plain, u.Password)
 }
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// UserIdentity links a local account to an account at an external OpenID
+// Connect provider, identified by the provider's issuer and subject.
+type UserIdentity struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    uint      `gorm:"not null;index" json:"user_id"`
+	User      User      `gorm:"foreignKey:UserID" json:"-"`
+	Issuer    string    `gorm:"not null;size:255;uniqueIndex:idx_user_identities_issuer_subject" json:"issuer"`
+	Subject   string    `gorm:"not null;size:255;uniqueIndex:idx_user_identities_issuer_subject" json:"subject"`
+	Email     string    `gorm:"size:255" json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
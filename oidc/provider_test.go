@@ -0,0 +1,204 @@
+package oidc_test
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Jason-cqtan/simple-blog/oidc"
+	"github.com/Jason-cqtan/simple-blog/oidc/oidctest"
+)
+
+const redirectURL = "http://blog.test/auth/oidc/callback"
+
+func newProvider(srv *oidctest.Server) *oidc.Provider {
+	return oidc.NewProvider(oidc.Config{
+		Issuer:       srv.Issuer(),
+		ClientID:     srv.ClientID,
+		ClientSecret: srv.ClientSecret,
+		RedirectURL:  redirectURL,
+	})
+}
+
+// authorize runs the browser leg of the flow and returns the code the
+// provider sent back.
+func authorize(t *testing.T, p *oidc.Provider, nonce, challenge string) string {
+	t.Helper()
+	authURL, err := p.AuthCodeURL("state-1", nonce, challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize: status %d", resp.StatusCode)
+	}
+	loc, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := loc.Query().Get("state"); got != "state-1" {
+		t.Fatalf("authorize: state %q, want state-1", got)
+	}
+	return loc.Query().Get("code")
+}
+
+func TestDiscover(t *testing.T) {
+	srv := oidctest.NewServer("blog", "s3cret")
+	defer srv.Close()
+
+	d, err := newProvider(srv).Discover()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.TokenEndpoint != srv.URL+"/token" {
+		t.Errorf("token endpoint = %q", d.TokenEndpoint)
+	}
+
+	wrongIssuer := oidc.NewProvider(oidc.Config{Issuer: srv.Issuer() + "/", ClientID: "blog"})
+	if _, err := wrongIssuer.Discover(); err == nil || !strings.Contains(err.Error(), "issuer mismatch") {
+		t.Errorf("issuer with trailing slash: err = %v, want issuer mismatch", err)
+	}
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	srv := oidctest.NewServer("blog", "s3cret")
+	defer srv.Close()
+
+	raw, err := newProvider(srv).AuthCodeURL("st", "no", "ch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             "blog",
+		"redirect_uri":          redirectURL,
+		"scope":                 "openid email profile",
+		"state":                 "st",
+		"nonce":                 "no",
+		"code_challenge":        "ch",
+		"code_challenge_method": "S256",
+	}
+	for k, v := range want {
+		if got := u.Query().Get(k); got != v {
+			t.Errorf("%s = %q, want %q", k, got, v)
+		}
+	}
+}
+
+func TestExchangeAndVerify(t *testing.T) {
+	tests := []struct {
+		name        string
+		ttl         time.Duration
+		verifier    func(real string) string
+		secret      string
+		verifyNonce string
+		verifyAs    string
+		wantExch    bool // Exchange fails
+		wantErr     string
+	}{
+		{name: "valid"},
+		{name: "wrong nonce", verifyNonce: "other-nonce", wantErr: "nonce mismatch"},
+		{name: "expired", ttl: -time.Hour, wantErr: "expired"},
+		{name: "other audience", verifyAs: "someone-else", wantErr: "audience"},
+		{name: "wrong PKCE verifier", verifier: func(string) string { return "not-the-verifier" }, wantExch: true},
+		{name: "wrong client secret", secret: "guess", wantExch: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := oidctest.NewServer("blog", "s3cret")
+			defer srv.Close()
+			if tt.ttl != 0 {
+				srv.SetTokenTTL(tt.ttl)
+			}
+
+			p := newProvider(srv)
+			if tt.secret != "" {
+				p = oidc.NewProvider(oidc.Config{Issuer: srv.Issuer(), ClientID: "blog", ClientSecret: tt.secret, RedirectURL: redirectURL})
+			}
+			verifier, challenge, err := oidc.NewPKCE()
+			if err != nil {
+				t.Fatal(err)
+			}
+			code := authorize(t, p, "nonce-1", challenge)
+			if tt.verifier != nil {
+				verifier = tt.verifier(verifier)
+			}
+
+			raw, err := p.Exchange(code, verifier)
+			if tt.wantExch {
+				if err == nil {
+					t.Fatal("Exchange succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			nonce := "nonce-1"
+			if tt.verifyNonce != "" {
+				nonce = tt.verifyNonce
+			}
+			verifyWith := p
+			if tt.verifyAs != "" {
+				verifyWith = oidc.NewProvider(oidc.Config{Issuer: srv.Issuer(), ClientID: tt.verifyAs})
+			}
+			claims, err := verifyWith.VerifyIDToken(raw, nonce)
+			if tt.wantErr != "" {
+				if !errors.Is(err, oidc.ErrInvalidIDToken) || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want ErrInvalidIDToken mentioning %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims.Subject != "user-1" || claims.Email != "sso.user@example.com" || !claims.EmailVerified {
+				t.Errorf("claims = %+v", claims)
+			}
+		})
+	}
+}
+
+func TestVerifyIDTokenRejectsTampering(t *testing.T) {
+	srv := oidctest.NewServer("blog", "s3cret")
+	defer srv.Close()
+	p := newProvider(srv)
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := p.Exchange(authorize(t, p, "n", challenge), verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts := strings.Split(raw, ".")
+	sig := []byte(parts[2])
+	sig[0] ^= 'A' ^ 'B'
+	tampered := parts[0] + "." + parts[1] + "." + string(sig)
+	if _, err := p.VerifyIDToken(tampered, "n"); !errors.Is(err, oidc.ErrInvalidIDToken) {
+		t.Errorf("tampered signature: err = %v, want ErrInvalidIDToken", err)
+	}
+}
+
+func TestS256Challenge(t *testing.T) {
+	// RFC 7636, appendix B.
+	got := oidc.S256Challenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk")
+	if want := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"; got != want {
+		t.Errorf("S256Challenge = %q, want %q", got, want)
+	}
+}
@@ -3,36 +3,149 @@ package routes
 import (
 	"github.com/Jason-cqtan/simple-blog/config"
 	"github.com/Jason-cqtan/simple-blog/handlers"
+	"github.com/Jason-cqtan/simple-blog/mailer"
 	"github.com/Jason-cqtan/simple-blog/middleware"
+	"github.com/Jason-cqtan/simple-blog/models"
+	"github.com/Jason-cqtan/simple-blog/oidc"
+	"github.com/Jason-cqtan/simple-blog/throttle"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
-func SetupRoutes(router *gin.Engine, db *gorm.DB, cfg *config.Config) {
-	userHandler := handlers.NewUserHandler(db, cfg)
+func SetupRoutes(router *gin.Engine, db *gorm.DB, cfg *config.Config, mail mailer.Mailer, guard *throttle.LoginGuard) {
+	userHandler := handlers.NewUserHandler(db, cfg, mail, guard)
+	passwordHandler := handlers.NewPasswordHandler(db, cfg, mail)
+	profileHandler := handlers.NewProfileHandler(db, cfg, mail)
+	accountHandler := handlers.NewAccountHandler(db, cfg)
+	mfaHandler := handlers.NewMFAHandler(db, cfg)
+	tokenHandler := handlers.NewTokenHandler(db)
+	authorHandler := handlers.NewAuthorHandler(db, cfg)
 	postHandler := handlers.NewPostHandler(db)
 	commentHandler := handlers.NewCommentHandler(db)
+	adminHandler := handlers.NewAdminHandler(db, cfg, mail, guard)
+	jwksHandler := handlers.NewJWKSHandler(cfg)
+	inviteHandler := handlers.NewInviteHandler(db, cfg)
+	sessionHandler := handlers.NewSessionHandler(db, cfg)
+	revisionHandler := handlers.NewRevisionHandler(db)
+	tagHandler := handlers.NewTagHandler(db)
+	categoryHandler := handlers.NewCategoryHandler(db)
+
+	// Bodies are capped before the CSRF check may parse a form.
+	router.Use(middleware.BodyLimit(int64(cfg.MaxBodyBytes)))
+
+	// State-changing requests must echo the CSRF token. Refresh is exempt: it
+	// only rotates the caller's own tokens and its response is not readable
+	// cross-site.
+	router.Use(middleware.CSRF(cfg, "/auth/refresh"))
 
 	// Public routes
 	router.GET("/", postHandler.Home)
 	router.GET("/posts", postHandler.List)
 	router.GET("/posts/:id", postHandler.Show)
+	router.GET("/:year/:month/:slug", postHandler.ShowBySlug)
+	router.GET("/tags", tagHandler.Index)
+	router.GET("/tags/:slug", tagHandler.Show)
+	router.GET("/categories", categoryHandler.Index)
+	router.GET("/categories/:slug", categoryHandler.Show)
+	router.GET("/users/:username", authorHandler.Show)
+	router.GET("/users/:username/feed.xml", authorHandler.Feed)
 	router.GET("/login", userHandler.ShowLoginForm)
 	router.GET("/register", userHandler.ShowRegisterForm)
 	router.POST("/login", userHandler.Login)
+	router.POST("/login/2fa", userHandler.LoginMFA)
 	router.POST("/register", userHandler.Register)
 	router.POST("/logout", userHandler.Logout)
+	router.POST("/auth/refresh", userHandler.Refresh)
+	router.GET("/verify-email", userHandler.VerifyEmail)
+	router.GET("/profile/email/confirm", profileHandler.ConfirmEmail)
+	router.GET("/.well-known/jwks.json", jwksHandler.Show)
+	router.GET("/forgot-password", passwordHandler.ShowForgotForm)
+	router.POST("/forgot-password", passwordHandler.Forgot)
+	router.GET("/reset-password", passwordHandler.ShowResetForm)
+	router.POST("/reset-password", passwordHandler.Reset)
+
+	// Single sign-on, only when an OpenID Connect provider is configured
+	if cfg.OIDCIssuer != "" {
+		oidcHandler := handlers.NewOIDCHandler(db, cfg, oidc.NewProvider(oidc.Config{
+			Issuer:       cfg.OIDCIssuer,
+			ClientID:     cfg.OIDCClientID,
+			ClientSecret: cfg.OIDCClientSecret,
+			RedirectURL:  cfg.OIDCRedirectURL,
+			Scopes:       cfg.OIDCScopes,
+		}))
+		router.GET("/auth/oidc/login", oidcHandler.Login)
+		router.GET("/auth/oidc/callback", oidcHandler.Callback)
+	}
+
+	authenticated := middleware.JWTAuthMiddleware(db, cfg)
+
+	// Account routes, for signed-in users only (not personal access tokens)
+	account := router.Group("/", authenticated, middleware.RequireSession())
+	{
+		account.GET("/profile", userHandler.ShowProfile)
+		account.GET("/profile/edit", profileHandler.ShowEditForm)
+		account.POST("/profile", profileHandler.Update)
+		account.POST("/profile/password", profileHandler.ChangePassword)
+		account.POST("/profile/avatar", profileHandler.UploadAvatar)
+		account.POST("/profile/avatar/delete", profileHandler.RemoveAvatar)
+		account.GET("/profile/export", accountHandler.Export)
+		account.GET("/profile/delete", accountHandler.ShowDeleteForm)
+		account.POST("/profile/delete", accountHandler.Delete)
+		account.POST("/logout/all", userHandler.LogoutAll)
+		account.POST("/verify-email/resend", userHandler.ResendVerification)
+		account.GET("/profile/2fa", mfaHandler.Show)
+		account.GET("/profile/2fa/qr.png", mfaHandler.QRCode)
+		account.POST("/profile/2fa/enable", mfaHandler.Enable)
+		account.POST("/profile/2fa/disable", mfaHandler.Disable)
+		account.POST("/profile/2fa/recovery-codes", mfaHandler.RegenerateRecoveryCodes)
+		account.GET("/profile/tokens", tokenHandler.List)
+		account.POST("/profile/tokens", tokenHandler.Create)
+		account.POST("/profile/tokens/:id/revoke", tokenHandler.Revoke)
+		account.GET("/profile/sessions", sessionHandler.List)
+		account.POST("/profile/sessions/:id/revoke", sessionHandler.Revoke)
+		account.GET("/profile/invites", middleware.RequirePermission(models.PermUserInvite), inviteHandler.List)
+		account.POST("/profile/invites", middleware.RequirePermission(models.PermUserInvite), inviteHandler.Create)
+		account.POST("/profile/invites/:id/revoke", inviteHandler.Revoke)
+	}
+
+	// Content routes, also open to personal access tokens with the right
+	// scope. Roles listed in MFA_REQUIRED_ROLES may only use them after
+	// signing in with a second factor.
+	member := router.Group("/", authenticated, middleware.RequireMFAForRoles(cfg.MFARequiredRoles))
+	{
+		member.GET("/posts/new", middleware.RequireScope(models.ScopePostsWrite), middleware.RequirePermission(models.PermPostCreate), middleware.RequireVerifiedEmail(cfg), postHandler.ShowCreateForm)
+		member.POST("/posts", middleware.RequireScope(models.ScopePostsWrite), middleware.RequirePermission(models.PermPostCreate), middleware.RequireVerifiedEmail(cfg), postHandler.Create)
+		member.GET("/posts/:id/preview", middleware.RequireScope(models.ScopePostsRead), postHandler.Preview)
+		member.GET("/posts/:id/edit", middleware.RequireScope(models.ScopePostsRead), postHandler.ShowEditForm)
+		member.POST("/posts/:id/update", middleware.RequireScope(models.ScopePostsWrite), postHandler.Update)
+		member.GET("/posts/:id/revisions", middleware.RequireScope(models.ScopePostsRead), revisionHandler.List)
+		member.GET("/posts/:id/revisions/diff", middleware.RequireScope(models.ScopePostsRead), revisionHandler.Diff)
+		member.GET("/posts/:id/revisions/:number", middleware.RequireScope(models.ScopePostsRead), revisionHandler.Show)
+		member.POST("/posts/:id/revisions/:number/restore", middleware.RequireScope(models.ScopePostsWrite), revisionHandler.Restore)
+		member.POST("/posts/:id/delete", middleware.RequireScope(models.ScopePostsWrite), postHandler.Delete)
+		member.POST("/posts/:id/comments", middleware.RequireScope(models.ScopeCommentsWrite), middleware.RequirePermission(models.PermCommentCreate), middleware.RequireVerifiedEmail(cfg), commentHandler.Create)
+		member.POST("/comments/:id/delete", middleware.RequireScope(models.ScopeCommentsWrite), commentHandler.Delete)
+	}
 
-	// Protected routes
-	auth := router.Group("/")
-	auth.Use(middleware.JWTAuthMiddleware(cfg.JWTSecret))
+	// Admin routes always require a browser session verified with 2FA
+	admin := router.Group("/admin", authenticated, middleware.RequireSession(), middleware.RequirePermission(models.PermUserManage), middleware.RequireMFA())
 	{
-		auth.GET("/posts/new", postHandler.ShowCreateForm)
-		auth.POST("/posts", postHandler.Create)
-		auth.GET("/posts/:id/edit", postHandler.ShowEditForm)
-		auth.POST("/posts/:id/update", postHandler.Update)
-		auth.POST("/posts/:id/delete", postHandler.Delete)
-		auth.POST("/posts/:id/comments", commentHandler.Create)
-		auth.GET("/profile", userHandler.ShowProfile)
+		admin.GET("/users", adminHandler.ListUsers)
+		admin.GET("/users/:id", adminHandler.ShowUser)
+		admin.POST("/users/:id/status", adminHandler.SetStatus)
+		admin.POST("/users/:id/role", adminHandler.ChangeRole)
+		admin.POST("/users/:id/password-reset", adminHandler.RequirePasswordReset)
+		admin.POST("/users/:id/sessions/revoke", adminHandler.RevokeSessions)
+		admin.GET("/invites", inviteHandler.ListAll)
+		admin.POST("/invites/:id/revoke", inviteHandler.RevokeAny)
+		admin.POST("/users/:id/unlock", adminHandler.Unlock)
+		admin.GET("/tags", tagHandler.AdminList)
+		admin.POST("/tags/:id/rename", tagHandler.Rename)
+		admin.POST("/tags/:id/merge", tagHandler.Merge)
+		admin.GET("/categories", categoryHandler.AdminList)
+		admin.POST("/categories", categoryHandler.Create)
+		admin.GET("/categories/:id/edit", categoryHandler.ShowEditForm)
+		admin.POST("/categories/:id", categoryHandler.Update)
+		admin.POST("/categories/:id/delete", categoryHandler.Delete)
 	}
 }
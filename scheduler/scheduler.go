@@ -0,0 +1,50 @@
+// Package scheduler publishes scheduled posts once their publish time
+// arrives.
+package scheduler
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Jason-cqtan/simple-blog/models"
+	"gorm.io/gorm"
+)
+
+// PublishDue publishes every scheduled post whose publish_at is not after
+// now, dating each by its scheduled time, and returns how many it published.
+// publish_at is left in place: MySQL evaluates SET clauses in order, so
+// clearing it here could clear published_at too.
+func PublishDue(db *gorm.DB, now time.Time) (int64, error) {
+	result := db.Model(&models.Post{}).
+		Where("status = ? AND publish_at <= ?", models.PostStatusScheduled, now).
+		Updates(map[string]interface{}{
+			"status":       models.PostStatusPublished,
+			"published":    true,
+			"published_at": gorm.Expr("COALESCE(published_at, publish_at)"),
+		})
+	return result.RowsAffected, result.Error
+}
+
+// Run calls PublishDue at once and then every interval until ctx is done.
+// Every instance may run it: the update is a single conditional statement,
+// so a post is never published twice.
+func Run(ctx context.Context, db *gorm.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := PublishDue(db, time.Now())
+		if err != nil {
+			log.Printf("publish scheduled posts: %v", err)
+		} else if n > 0 {
+			log.Printf("Published %d scheduled post(s).", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
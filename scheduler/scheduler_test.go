@@ -0,0 +1,84 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Jason-cqtan/simple-blog/internal/testdb"
+	"github.com/Jason-cqtan/simple-blog/models"
+)
+
+func TestPublishDue(t *testing.T) {
+	db := testdb.Open(t, &models.User{}, &models.Post{}, &models.PostSlug{}, &models.PostRevision{})
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+	firstPublished := now.AddDate(0, -2, 0)
+
+	author := models.User{Username: "sam", Email: "sam@example.com", Password: "x", RoleID: 1}
+	if err := db.Create(&author).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		status        string
+		publishAt     *time.Time
+		publishedAt   *time.Time
+		wantStatus    string
+		wantPublished *time.Time
+	}{
+		{name: "due", status: models.PostStatusScheduled, publishAt: &past,
+			wantStatus: models.PostStatusPublished, wantPublished: &past},
+		{name: "due now", status: models.PostStatusScheduled, publishAt: &now,
+			wantStatus: models.PostStatusPublished, wantPublished: &now},
+		{name: "rescheduled", status: models.PostStatusScheduled, publishAt: &past, publishedAt: &firstPublished,
+			wantStatus: models.PostStatusPublished, wantPublished: &firstPublished},
+		{name: "not yet due", status: models.PostStatusScheduled, publishAt: &future,
+			wantStatus: models.PostStatusScheduled},
+		{name: "draft", status: models.PostStatusDraft, publishAt: &past,
+			wantStatus: models.PostStatusDraft},
+	}
+
+	ids := make([]uint, len(tests))
+	for i, tt := range tests {
+		post := models.Post{Title: tt.name, Content: "x", AuthorID: author.ID,
+			Status: tt.status, PublishAt: tt.publishAt, PublishedAt: tt.publishedAt}
+		if err := db.Create(&post).Error; err != nil {
+			t.Fatal(err)
+		}
+		ids[i] = post.ID
+	}
+
+	n, err := PublishDue(db, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("PublishDue published %d posts, want 3", n)
+	}
+
+	for i, tt := range tests {
+		var post models.Post
+		if err := db.First(&post, ids[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+		if post.Status != tt.wantStatus {
+			t.Errorf("%s: status = %q, want %q", tt.name, post.Status, tt.wantStatus)
+		}
+		if post.Published != (tt.wantStatus == models.PostStatusPublished) {
+			t.Errorf("%s: published = %v with status %q", tt.name, post.Published, post.Status)
+		}
+		switch {
+		case tt.wantPublished == nil && post.PublishedAt != nil:
+			t.Errorf("%s: published_at = %v, want none", tt.name, *post.PublishedAt)
+		case tt.wantPublished != nil && (post.PublishedAt == nil || !post.PublishedAt.Equal(*tt.wantPublished)):
+			t.Errorf("%s: published_at = %v, want %v", tt.name, post.PublishedAt, *tt.wantPublished)
+		}
+	}
+
+	// A second run finds nothing left to publish.
+	if n, err := PublishDue(db, now); err != nil || n != 0 {
+		t.Errorf("second PublishDue = %d, %v; want 0, nil", n, err)
+	}
+}
@@ -0,0 +1,80 @@
+package throttle
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Jason-cqtan/simple-blog/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DBStore keeps records in the login_attempts table, so every application
+// replica sees the same counts.
+type DBStore struct {
+	db *gorm.DB
+}
+
+func NewDBStore(db *gorm.DB) *DBStore {
+	return &DBStore{db: db}
+}
+
+func (s *DBStore) Get(key string) (Record, error) {
+	var row models.LoginAttempt
+	err := s.db.Where(&models.LoginAttempt{Key: key}).First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Record{}, nil
+	}
+	if err != nil {
+		return Record{}, err
+	}
+	return recordFromRow(&row), nil
+}
+
+func (s *DBStore) Update(key string, fn func(*Record)) (Record, error) {
+	var rec Record
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Make sure the row exists, then lock it for the read-modify-write.
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.LoginAttempt{Key: key}).Error; err != nil {
+			return err
+		}
+		var row models.LoginAttempt
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where(&models.LoginAttempt{Key: key}).First(&row).Error; err != nil {
+			return err
+		}
+
+		rec = recordFromRow(&row)
+		fn(&rec)
+
+		row.Failures = rec.Failures
+		row.LastFailureAt = nullTime(rec.LastFailureAt)
+		row.LockedUntil = nullTime(rec.LockedUntil)
+		return tx.Save(&row).Error
+	})
+	return rec, err
+}
+
+func (s *DBStore) Delete(key string) error {
+	return s.db.Delete(&models.LoginAttempt{Key: key}).Error
+}
+
+func recordFromRow(row *models.LoginAttempt) Record {
+	rec := Record{Failures: row.Failures}
+	if row.LastFailureAt != nil {
+		rec.LastFailureAt = *row.LastFailureAt
+	}
+	if row.LockedUntil != nil {
+		rec.LockedUntil = *row.LockedUntil
+	}
+	return rec
+}
+
+// nullTime stores the zero time as NULL.
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
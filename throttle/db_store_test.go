@@ -0,0 +1,64 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Jason-cqtan/simple-blog/internal/testdb"
+	"github.com/Jason-cqtan/simple-blog/models"
+)
+
+func TestDBStoreStoresUnsetTimesAsNull(t *testing.T) {
+	db := testdb.Open(t, &models.LoginAttempt{})
+	store := NewDBStore(db)
+
+	// A failure below the lockout threshold sets no lockout time.
+	now := time.Now().UTC().Truncate(time.Second)
+	if _, err := store.Update("ip:1", func(r *Record) {
+		r.Failures++
+		r.LastFailureAt = now
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var row models.LoginAttempt
+	if err := db.First(&row, "key = ?", "ip:1").Error; err != nil {
+		t.Fatal(err)
+	}
+	if row.LockedUntil != nil {
+		t.Errorf("locked_until = %v, want NULL", *row.LockedUntil)
+	}
+	if row.LastFailureAt == nil || !row.LastFailureAt.Equal(now) {
+		t.Errorf("last_failure_at = %v, want %v", row.LastFailureAt, now)
+	}
+
+	rec, err := store.Get("ip:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Failures != 1 || !rec.LastFailureAt.Equal(now) || !rec.LockedUntil.IsZero() {
+		t.Errorf("Get = %+v", rec)
+	}
+}
+
+func TestLimiterWithDBStore(t *testing.T) {
+	store := NewDBStore(testdb.Open(t, &models.LoginAttempt{}))
+	limiter := NewLimiter(store, Policy{FreeAttempts: 2, BaseDelay: time.Minute, MaxDelay: time.Hour, Window: time.Hour})
+
+	for i, want := range []bool{false, false, true, true} {
+		wait, err := limiter.Fail("user:a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if locked := wait > 0; locked != want {
+			t.Errorf("failure %d: locked = %v (wait %v), want %v", i+1, locked, wait, want)
+		}
+	}
+
+	if err := store.Delete("user:a"); err != nil {
+		t.Fatal(err)
+	}
+	if rec, err := store.Get("user:a"); err != nil || rec.Failures != 0 {
+		t.Errorf("after Delete: %+v, %v", rec, err)
+	}
+}
@@ -0,0 +1,57 @@
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// pruneAfter is how long an idle record is kept by MemoryStore.
+const pruneAfter = 24 * time.Hour
+
+// MemoryStore keeps records in process memory. It is only suitable for a
+// single application instance.
+type MemoryStore struct {
+	mu        sync.Mutex
+	records   map[string]Record
+	lastPrune time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[string]Record)}
+}
+
+func (s *MemoryStore) Get(key string) (Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.records[key], nil
+}
+
+func (s *MemoryStore) Update(key string, fn func(*Record)) (Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked()
+	rec := s.records[key]
+	fn(&rec)
+	s.records[key] = rec
+	return rec, nil
+}
+
+func (s *MemoryStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+func (s *MemoryStore) pruneLocked() {
+	now := time.Now()
+	if now.Sub(s.lastPrune) < time.Hour {
+		return
+	}
+	s.lastPrune = now
+	for key, rec := range s.records {
+		if now.After(rec.LockedUntil) && now.Sub(rec.LastFailureAt) > pruneAfter {
+			delete(s.records, key)
+		}
+	}
+}
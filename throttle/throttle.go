@@ -0,0 +1,91 @@
+// Package throttle tracks failed attempts per key (an IP address, an
+// account) and locks a key out for exponentially growing periods.
+package throttle
+
+import "time"
+
+// Record is the failure history of one key.
+type Record struct {
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   time.Time
+}
+
+// Store persists records. Implementations must apply Update atomically, so
+// that several application replicas sharing a store count correctly.
+type Store interface {
+	Get(key string) (Record, error)
+	Update(key string, fn func(*Record)) (Record, error)
+	Delete(key string) error
+}
+
+// Policy controls when and for how long a key is locked.
+type Policy struct {
+	// FreeAttempts is how many failures are allowed before lockouts start.
+	FreeAttempts int
+	// BaseDelay is the first lockout; each further failure doubles it.
+	BaseDelay time.Duration
+	// MaxDelay caps the lockout.
+	MaxDelay time.Duration
+	// Window is how long after the last failure the count is forgotten.
+	Window time.Duration
+}
+
+type Limiter struct {
+	store  Store
+	policy Policy
+}
+
+func NewLimiter(store Store, policy Policy) *Limiter {
+	return &Limiter{store: store, policy: policy}
+}
+
+// Wait returns how long key remains locked, or zero.
+func (l *Limiter) Wait(key string) (time.Duration, error) {
+	rec, err := l.store.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	if wait := time.Until(rec.LockedUntil); wait > 0 {
+		return wait, nil
+	}
+	return 0, nil
+}
+
+// Fail records a failed attempt and returns the resulting lockout.
+func (l *Limiter) Fail(key string) (time.Duration, error) {
+	now := time.Now()
+	rec, err := l.store.Update(key, func(r *Record) {
+		if now.Sub(r.LastFailureAt) > l.policy.Window {
+			r.Failures = 0
+		}
+		r.Failures++
+		r.LastFailureAt = now
+		if over := r.Failures - l.policy.FreeAttempts; over > 0 {
+			r.LockedUntil = now.Add(l.delay(over))
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+	return time.Until(rec.LockedUntil), nil
+}
+
+// Reset forgets all failures for key.
+func (l *Limiter) Reset(key string) error {
+	return l.store.Delete(key)
+}
+
+func (l *Limiter) delay(over int) time.Duration {
+	d := l.policy.BaseDelay
+	for i := 1; i < over; i++ {
+		d *= 2
+		if d >= l.policy.MaxDelay {
+			return l.policy.MaxDelay
+		}
+	}
+	if d > l.policy.MaxDelay {
+		return l.policy.MaxDelay
+	}
+	return d
+}
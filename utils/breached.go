@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// BreachedPasswords looks passwords up in a local copy of a breach corpus
+// laid out like the Pwned Passwords range API: the SHA-1 of the password is
+// split into a five-character prefix, which names a file in Dir, and the
+// remaining suffix, which is listed in that file as "SUFFIX:COUNT" lines.
+// Only the prefix is ever used to find the file, so the list can be
+// refreshed from the range API without sending full hashes anywhere.
+type BreachedPasswords struct {
+	Dir string
+	// MinCount ignores hashes seen fewer times than this in breaches.
+	MinCount int
+}
+
+const breachedPrefixLen = 5
+
+// Contains reports whether password appears in the list at least MinCount
+// times. A missing prefix file means no hash with that prefix is listed.
+func (b *BreachedPasswords) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:breachedPrefixLen], hash[breachedPrefixLen:]
+
+	f, err := b.open(prefix)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = f.Close() }()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		entry, count, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if !strings.EqualFold(entry, suffix) {
+			continue
+		}
+		n, err := strconv.Atoi(count)
+		if err != nil {
+			// Lists without counts mark every entry as breached.
+			n = 1
+		}
+		return n >= b.MinCount, nil
+	}
+	return false, scanner.Err()
+}
+
+// open finds the prefix file, named either "ABCDE" or "ABCDE.txt".
+func (b *BreachedPasswords) open(prefix string) (*os.File, error) {
+	f, err := os.Open(filepath.Join(b.Dir, prefix))
+	if os.IsNotExist(err) {
+		f, err = os.Open(filepath.Join(b.Dir, prefix+".txt"))
+	}
+	return f, err
+}
@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// emailVerificationAudience keeps verification links from being accepted as
+// access tokens and vice versa.
+const emailVerificationAudience = "email-verification"
+
+type EmailClaims struct {
+	UserID uint   `json:"user_id"`
+	Email  string `json:"email"`
+	jwt.RegisteredClaims
+}
+
+// GenerateEmailToken signs a verification token bound to the user's current
+// address, so changing the address invalidates earlier links.
+func GenerateEmailToken(userID uint, email string, keys *Keyring, ttl time.Duration) (string, error) {
+	claims := EmailClaims{
+		UserID: userID,
+		Email:  email,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{emailVerificationAudience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+	return keys.Sign(claims)
+}
+
+func ParseEmailToken(tokenString string, keys *Keyring) (*EmailClaims, error) {
+	claims := &EmailClaims{}
+	if err := keys.Parse(tokenString, claims, jwt.WithAudience(emailVerificationAudience)); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// emailChangeAudience keeps email change links apart from verification links
+// and access tokens.
+const emailChangeAudience = "email-change"
+
+type EmailChangeClaims struct {
+	UserID   uint   `json:"user_id"`
+	Email    string `json:"email"`
+	NewEmail string `json:"new_email"`
+	jwt.RegisteredClaims
+}
+
+// GenerateEmailChangeToken signs a link confirming that the user owns
+// newEmail. It is bound to the current address, so it stops working once
+// the address has changed.
+func GenerateEmailChangeToken(userID uint, email, newEmail string, keys *Keyring, ttl time.Duration) (string, error) {
+	claims := EmailChangeClaims{
+		UserID:   userID,
+		Email:    email,
+		NewEmail: newEmail,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{emailChangeAudience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+	return keys.Sign(claims)
+}
+
+func ParseEmailChangeToken(tokenString string, keys *Keyring) (*EmailChangeClaims, error) {
+	claims := &EmailChangeClaims{}
+	if err := keys.Parse(tokenString, claims, jwt.WithAudience(emailChangeAudience)); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
@@ -0,0 +1,221 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrUnknownKey = errors.New("unknown signing key")
+	ErrNoSigner   = errors.New("no active signing key")
+)
+
+// signingKey is one entry of a Keyring. sign is nil for keys that may only
+// verify, such as a public key kept while tokens it signed expire.
+type signingKey struct {
+	id     string
+	method jwt.SigningMethod
+	sign   interface{}
+	verify interface{}
+}
+
+// Keyring signs tokens with its active key and verifies them with whichever
+// key the token's kid header names, so keys can be rotated without
+// invalidating tokens that are still in use.
+type Keyring struct {
+	keys   map[string]*signingKey
+	active string
+	// legacy verifies tokens issued without a kid header.
+	legacy string
+}
+
+func NewKeyring() *Keyring {
+	return &Keyring{keys: make(map[string]*signingKey)}
+}
+
+// AddHMAC adds an HS256 shared-secret key.
+func (k *Keyring) AddHMAC(kid string, secret []byte) error {
+	if len(secret) == 0 {
+		return errors.New("empty HMAC secret")
+	}
+	return k.add(&signingKey{id: kid, method: jwt.SigningMethodHS256, sign: secret, verify: secret})
+}
+
+// AddPEM adds an asymmetric key for alg (RS256, ES256 or EdDSA) from a PEM
+// block. A private key (PKCS#8, PKCS#1 or SEC 1) can sign and verify; a
+// public key (PKIX) can only verify.
+func (k *Keyring) AddPEM(kid, alg string, data []byte) error {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return errors.New("no PEM block found")
+	}
+
+	var (
+		private crypto.Signer
+		public  crypto.PublicKey
+	)
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return err
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return fmt.Errorf("unsupported private key type %T", key)
+		}
+		private = signer
+	case "RSA PRIVATE KEY":
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return err
+		}
+		private = key
+	case "EC PRIVATE KEY":
+		key, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return err
+		}
+		private = key
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return err
+		}
+		public = key
+	default:
+		return fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if private != nil {
+		public = private.Public()
+	}
+
+	method := jwt.GetSigningMethod(alg)
+	var ok bool
+	switch alg {
+	case "RS256":
+		_, ok = public.(*rsa.PublicKey)
+	case "ES256":
+		var ec *ecdsa.PublicKey
+		ec, ok = public.(*ecdsa.PublicKey)
+		ok = ok && ec.Curve.Params().Name == "P-256"
+	case "EdDSA":
+		_, ok = public.(ed25519.PublicKey)
+	default:
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	if !ok {
+		return fmt.Errorf("key type %T does not match algorithm %s", public, alg)
+	}
+
+	key := &signingKey{id: kid, method: method, verify: public}
+	if private != nil {
+		key.sign = private
+	}
+	return k.add(key)
+}
+
+func (k *Keyring) add(key *signingKey) error {
+	if key.id == "" {
+		return errors.New("key ID is required")
+	}
+	if _, exists := k.keys[key.id]; exists {
+		return fmt.Errorf("duplicate key ID %q", key.id)
+	}
+	k.keys[key.id] = key
+	return nil
+}
+
+// SetActive selects the key new tokens are signed with.
+func (k *Keyring) SetActive(kid string) error {
+	key, ok := k.keys[kid]
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnknownKey, kid)
+	}
+	if key.sign == nil {
+		return fmt.Errorf("key %q has no private key and cannot sign", kid)
+	}
+	k.active = kid
+	return nil
+}
+
+// SetLegacy selects the key that verifies tokens without a kid header.
+func (k *Keyring) SetLegacy(kid string) error {
+	if _, ok := k.keys[kid]; !ok {
+		return fmt.Errorf("%w %q", ErrUnknownKey, kid)
+	}
+	k.legacy = kid
+	return nil
+}
+
+// Sign signs claims with the active key and records its kid in the header.
+func (k *Keyring) Sign(claims jwt.Claims) (string, error) {
+	key, ok := k.keys[k.active]
+	if !ok {
+		return "", ErrNoSigner
+	}
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.id
+	return token.SignedString(key.sign)
+}
+
+// Parse verifies tokenString and decodes it into claims. The algorithm must
+// match the one registered for the token's key.
+func (k *Keyring) Parse(tokenString string, claims jwt.Claims, opts ...jwt.ParserOption) error {
+	token, err := jwt.ParseWithClaims(tokenString, claims, k.keyFunc, opts...)
+	if err != nil {
+		return err
+	}
+	if !token.Valid {
+		return errors.New("invalid token")
+	}
+	return nil
+}
+
+func (k *Keyring) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		kid = k.legacy
+	}
+	key, ok := k.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, errors.New("unexpected signing method")
+	}
+	return key.verify, nil
+}
+
+// JWKS returns the public keys of the ring. Shared-secret keys are never
+// published.
+func (k *Keyring) JWKS() (JWKSet, error) {
+	set := JWKSet{Keys: []JWK{}}
+	ids := make([]string, 0, len(k.keys))
+	for id := range k.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		key := k.keys[id]
+		if _, ok := key.verify.([]byte); ok {
+			continue
+		}
+		jwk, err := NewJWK(key.verify, key.id, key.method.Alg())
+		if err != nil {
+			return JWKSet{}, err
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set, nil
+}
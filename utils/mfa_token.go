@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// mfaLoginAudience marks the short-lived token that carries a user from the
+// password step of login to the two-factor step.
+const mfaLoginAudience = "mfa-login"
+
+type MFAClaims struct {
+	UserID uint `json:"user_id"`
+	jwt.RegisteredClaims
+}
+
+func GenerateMFAToken(userID uint, keys *Keyring, ttl time.Duration) (string, error) {
+	claims := MFAClaims{
+		UserID: userID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{mfaLoginAudience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+	return keys.Sign(claims)
+}
+
+func ParseMFAToken(tokenString string, keys *Keyring) (*MFAClaims, error) {
+	claims := &MFAClaims{}
+	if err := keys.Parse(tokenString, claims, jwt.WithAudience(mfaLoginAudience)); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
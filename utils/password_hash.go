@@ -0,0 +1,161 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Password hashing algorithms a PasswordHasher can use.
+const (
+	HashArgon2id = "argon2id"
+	HashBcrypt   = "bcrypt"
+)
+
+const (
+	argon2idPrefix  = "$argon2id$"
+	argon2SaltBytes = 16
+	argon2KeyBytes  = 32
+)
+
+// The longest passwords each hasher accepts. bcrypt ignores everything after
+// 72 bytes; argon2id has no such limit, but hashing is bounded so huge inputs
+// cannot be used to tie up the server.
+const (
+	bcryptMaxPasswordBytes   = 72
+	argon2idMaxPasswordBytes = 1024
+)
+
+// PasswordHasher hashes new passwords with one algorithm and parameter set.
+// Stored hashes carry an algorithm prefix ("$argon2id$" or bcrypt's "$2a$"),
+// so VerifyPassword can check them whichever hasher made them.
+type PasswordHasher interface {
+	Hash(plain string) (string, error)
+	// NeedsRehash reports whether encoded was made with another algorithm or
+	// other parameters than the hasher's own.
+	NeedsRehash(encoded string) bool
+	// MaxPasswordBytes is the longest password the algorithm hashes in full.
+	MaxPasswordBytes() int
+}
+
+// NewPasswordHasher returns the hasher for algorithm. bcryptCost applies to
+// bcrypt; memory (KiB), iterations and parallelism to argon2id.
+func NewPasswordHasher(algorithm string, bcryptCost int, memory, iterations uint32, parallelism uint8) (PasswordHasher, error) {
+	switch algorithm {
+	case HashArgon2id:
+		if memory < 8*uint32(parallelism) || iterations < 1 || parallelism < 1 {
+			return nil, fmt.Errorf("invalid argon2id parameters m=%d t=%d p=%d", memory, iterations, parallelism)
+		}
+		return &Argon2idHasher{Memory: memory, Iterations: iterations, Parallelism: parallelism}, nil
+	case HashBcrypt:
+		if bcryptCost < bcrypt.MinCost || bcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+		return &BcryptHasher{Cost: bcryptCost}, nil
+	}
+	return nil, fmt.Errorf("unknown password hash algorithm %q", algorithm)
+}
+
+// VerifyPassword reports whether plain matches encoded, a hash made by any
+// supported algorithm.
+func VerifyPassword(plain, encoded string) bool {
+	if strings.HasPrefix(encoded, argon2idPrefix) {
+		params, salt, key, err := decodeArgon2id(encoded)
+		if err != nil {
+			return false
+		}
+		derived := argon2.IDKey([]byte(plain), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+		return subtle.ConstantTimeCompare(derived, key) == 1
+	}
+	return bcrypt.CompareHashAndPassword([]byte(encoded), []byte(plain)) == nil
+}
+
+// BcryptHasher hashes passwords with bcrypt at Cost.
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h *BcryptHasher) Hash(plain string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(plain), h.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
+
+func (h *BcryptHasher) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != h.Cost
+}
+
+func (h *BcryptHasher) MaxPasswordBytes() int {
+	return bcryptMaxPasswordBytes
+}
+
+// Argon2idHasher hashes passwords with argon2id and encodes them in the PHC
+// string format: $argon2id$v=19$m=<KiB>,t=<iterations>,p=<threads>$salt$key.
+type Argon2idHasher struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+func (h *Argon2idHasher) Hash(plain string) (string, error) {
+	salt := make([]byte, argon2SaltBytes)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(plain), salt, h.Iterations, h.Memory, h.Parallelism, argon2KeyBytes)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2idPrefix, argon2.Version,
+		h.Memory, h.Iterations, h.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (h *Argon2idHasher) NeedsRehash(encoded string) bool {
+	if !strings.HasPrefix(encoded, argon2idPrefix) {
+		return true
+	}
+	params, _, key, err := decodeArgon2id(encoded)
+	return err != nil || *params != *h || len(key) != argon2KeyBytes
+}
+
+func (h *Argon2idHasher) MaxPasswordBytes() int {
+	return argon2idMaxPasswordBytes
+}
+
+// decodeArgon2id splits a PHC-format argon2id hash into its parameters, salt
+// and derived key.
+func decodeArgon2id(encoded string) (*Argon2idHasher, []byte, []byte, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return nil, nil, nil, fmt.Errorf("malformed argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, nil, nil, fmt.Errorf("unsupported argon2id version %q", parts[2])
+	}
+	params := &Argon2idHasher{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return nil, nil, nil, fmt.Errorf("malformed argon2id parameters: %w", err)
+	}
+	if params.Iterations < 1 || params.Parallelism < 1 {
+		return nil, nil, nil, fmt.Errorf("invalid argon2id parameters %q", parts[3])
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return nil, nil, nil, fmt.Errorf("malformed argon2id key")
+	}
+	return params, salt, key, nil
+}
@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPasswordHashers(t *testing.T) {
+	bcryptHasher, err := NewPasswordHasher(HashBcrypt, bcrypt.MinCost, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	argonHasher, err := NewPasswordHasher(HashArgon2id, 0, 64, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, h := range []PasswordHasher{bcryptHasher, argonHasher} {
+		encoded, err := h.Hash("correct horse")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !VerifyPassword("correct horse", encoded) {
+			t.Errorf("%T: VerifyPassword rejected the right password", h)
+		}
+		if VerifyPassword("wrong horse", encoded) {
+			t.Errorf("%T: VerifyPassword accepted a wrong password", h)
+		}
+		if h.NeedsRehash(encoded) {
+			t.Errorf("%T: NeedsRehash(own hash) = true", h)
+		}
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	bcryptHash := mustHash(t, &BcryptHasher{Cost: bcrypt.MinCost})
+	argonHash := mustHash(t, &Argon2idHasher{Memory: 64, Iterations: 1, Parallelism: 1})
+
+	tests := []struct {
+		name    string
+		hasher  PasswordHasher
+		encoded string
+		want    bool
+	}{
+		{"bcrypt, same cost", &BcryptHasher{Cost: bcrypt.MinCost}, bcryptHash, false},
+		{"bcrypt, other cost", &BcryptHasher{Cost: bcrypt.MinCost + 1}, bcryptHash, true},
+		{"bcrypt, argon2id hash", &BcryptHasher{Cost: bcrypt.MinCost}, argonHash, true},
+		{"argon2id, same parameters", &Argon2idHasher{Memory: 64, Iterations: 1, Parallelism: 1}, argonHash, false},
+		{"argon2id, other memory", &Argon2idHasher{Memory: 128, Iterations: 1, Parallelism: 1}, argonHash, true},
+		{"argon2id, other iterations", &Argon2idHasher{Memory: 64, Iterations: 2, Parallelism: 1}, argonHash, true},
+		{"argon2id, bcrypt hash", &Argon2idHasher{Memory: 64, Iterations: 1, Parallelism: 1}, bcryptHash, true},
+		{"argon2id, malformed hash", &Argon2idHasher{Memory: 64, Iterations: 1, Parallelism: 1}, "$argon2id$v=19$garbage", true},
+	}
+	for _, tt := range tests {
+		if got := tt.hasher.NeedsRehash(tt.encoded); got != tt.want {
+			t.Errorf("%s: NeedsRehash = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// Either hash verifies whichever hasher is configured.
+	for _, encoded := range []string{bcryptHash, argonHash} {
+		if !VerifyPassword("correct horse", encoded) {
+			t.Errorf("VerifyPassword rejected %s", strings.SplitN(encoded, "$", 3)[1])
+		}
+	}
+}
+
+func TestNewPasswordHasherRejectsBadParameters(t *testing.T) {
+	tests := []struct {
+		algorithm   string
+		cost        int
+		memory      uint32
+		iterations  uint32
+		parallelism uint8
+	}{
+		{HashBcrypt, bcrypt.MinCost - 1, 0, 0, 0},
+		{HashBcrypt, bcrypt.MaxCost + 1, 0, 0, 0},
+		{HashArgon2id, 0, 4, 1, 1},
+		{HashArgon2id, 0, 64, 0, 1},
+		{HashArgon2id, 0, 64, 1, 0},
+		{"md5", 0, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		if _, err := NewPasswordHasher(tt.algorithm, tt.cost, tt.memory, tt.iterations, tt.parallelism); err == nil {
+			t.Errorf("NewPasswordHasher(%+v) succeeded, want an error", tt)
+		}
+	}
+}
+
+func mustHash(t *testing.T, h PasswordHasher) string {
+	t.Helper()
+	encoded, err := h.Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encoded
+}
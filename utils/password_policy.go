@@ -0,0 +1,131 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Character classes a PasswordPolicy can require.
+const (
+	ClassLower  = "lower"
+	ClassUpper  = "upper"
+	ClassDigit  = "digit"
+	ClassSymbol = "symbol"
+)
+
+var classRules = map[string]struct {
+	match   func(rune) bool
+	message string
+}{
+	ClassLower:  {unicode.IsLower, "password must contain a lowercase letter"},
+	ClassUpper:  {unicode.IsUpper, "password must contain an uppercase letter"},
+	ClassDigit:  {unicode.IsDigit, "password must contain a digit"},
+	ClassSymbol: {isSymbol, "password must contain a symbol"},
+}
+
+func isSymbol(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsSpace(r)
+}
+
+// PasswordError lists every rule a password failed, so the user can fix
+// them all at once.
+type PasswordError struct {
+	Problems []string
+}
+
+func (e *PasswordError) Error() string {
+	return strings.Join(e.Problems, "; ")
+}
+
+// PasswordPolicy decides which passwords may be set.
+type PasswordPolicy struct {
+	MinLength int
+	// MaxBytes is the longest password the password hasher takes in full
+	// (PasswordHasher.MaxPasswordBytes); longer ones are rejected instead of
+	// silently truncated.
+	MaxBytes int
+	// Require lists character classes (ClassLower, ClassUpper, ClassDigit,
+	// ClassSymbol) that must each appear at least once.
+	Require []string
+	// ForbidIdentity rejects passwords containing the username or email.
+	ForbidIdentity bool
+	// Breached, when set, rejects passwords found in a breach corpus.
+	Breached *BreachedPasswords
+}
+
+// NewPasswordPolicy checks that every required class is known.
+func NewPasswordPolicy(minLength, maxBytes int, require []string, forbidIdentity bool, breached *BreachedPasswords) (*PasswordPolicy, error) {
+	for _, class := range require {
+		if _, ok := classRules[class]; !ok {
+			return nil, fmt.Errorf("unknown character class %q", class)
+		}
+	}
+	return &PasswordPolicy{
+		MinLength:      minLength,
+		MaxBytes:       maxBytes,
+		Require:        require,
+		ForbidIdentity: forbidIdentity,
+		Breached:       breached,
+	}, nil
+}
+
+// Validate checks password against the policy. username and email are the
+// account's, used by ForbidIdentity. The error is a *PasswordError.
+func (p *PasswordPolicy) Validate(password, username, email string) error {
+	if password == "" {
+		return &PasswordError{Problems: []string{"password is required"}}
+	}
+
+	var problems []string
+	if utf8.RuneCountInString(password) < p.MinLength {
+		problems = append(problems, fmt.Sprintf("password must be at least %d characters", p.MinLength))
+	}
+	if p.MaxBytes > 0 && len(password) > p.MaxBytes {
+		problems = append(problems, fmt.Sprintf("password must be at most %d bytes", p.MaxBytes))
+	}
+	for _, class := range p.Require {
+		rule := classRules[class]
+		if strings.IndexFunc(password, rule.match) < 0 {
+			problems = append(problems, rule.message)
+		}
+	}
+	if p.ForbidIdentity && containsIdentity(password, username, email) {
+		problems = append(problems, "password must not contain your username or email address")
+	}
+	if len(problems) == 0 && p.Breached != nil {
+		breached, err := p.Breached.Contains(password)
+		if err != nil {
+			// The list is a second line of defence; an unreadable file
+			// should not block every password change.
+			log.Printf("breached password check: %v", err)
+		} else if breached {
+			problems = append(problems, "password has appeared in a data breach; choose a different one")
+		}
+	}
+
+	if len(problems) > 0 {
+		return &PasswordError{Problems: problems}
+	}
+	return nil
+}
+
+// containsIdentity reports whether password contains the username, the
+// email address or its local part, ignoring case. Parts shorter than three
+// characters are ignored so short names do not rule out most passwords.
+func containsIdentity(password, username, email string) bool {
+	password = strings.ToLower(password)
+	parts := []string{username, email}
+	if at := strings.LastIndex(email, "@"); at > 0 {
+		parts = append(parts, email[:at])
+	}
+	for _, part := range parts {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if utf8.RuneCountInString(part) >= 3 && strings.Contains(password, part) {
+			return true
+		}
+	}
+	return false
+}
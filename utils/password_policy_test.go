@@ -0,0 +1,140 @@
+package utils
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	bcrypt := &BcryptHasher{Cost: 4}
+	argon := &Argon2idHasher{Memory: 64, Iterations: 1, Parallelism: 1}
+
+	tests := []struct {
+		name     string
+		policy   PasswordPolicy
+		password string
+		want     []string // problems; nil means valid
+	}{
+		{name: "empty", policy: PasswordPolicy{MinLength: 8}, password: "", want: []string{"password is required"}},
+		{name: "valid", policy: PasswordPolicy{MinLength: 8}, password: "wintergreen7"},
+		{name: "too short", policy: PasswordPolicy{MinLength: 8}, password: "short", want: []string{"password must be at least 8 characters"}},
+		{name: "length counts characters", policy: PasswordPolicy{MinLength: 4}, password: "äöüß"},
+		{
+			name:     "bcrypt limit",
+			policy:   PasswordPolicy{MinLength: 8, MaxBytes: bcrypt.MaxPasswordBytes()},
+			password: strings.Repeat("a", 73),
+			want:     []string{"password must be at most 72 bytes"},
+		},
+		{name: "at the bcrypt limit", policy: PasswordPolicy{MinLength: 8, MaxBytes: bcrypt.MaxPasswordBytes()}, password: strings.Repeat("a", 72)},
+		{
+			name:     "limit counts bytes",
+			policy:   PasswordPolicy{MinLength: 8, MaxBytes: bcrypt.MaxPasswordBytes()},
+			password: strings.Repeat("ä", 37),
+			want:     []string{"password must be at most 72 bytes"},
+		},
+		{name: "argon2id allows long passwords", policy: PasswordPolicy{MinLength: 8, MaxBytes: argon.MaxPasswordBytes()}, password: strings.Repeat("a", 1024)},
+		{
+			name:     "argon2id limit",
+			policy:   PasswordPolicy{MinLength: 8, MaxBytes: argon.MaxPasswordBytes()},
+			password: strings.Repeat("a", 1025),
+			want:     []string{"password must be at most 1024 bytes"},
+		},
+		{
+			name:     "required classes",
+			policy:   PasswordPolicy{MinLength: 1, Require: []string{ClassLower, ClassUpper, ClassDigit, ClassSymbol}},
+			password: "abc",
+			want: []string{
+				"password must contain an uppercase letter",
+				"password must contain a digit",
+				"password must contain a symbol",
+			},
+		},
+		{name: "all classes", policy: PasswordPolicy{MinLength: 1, Require: []string{ClassLower, ClassUpper, ClassDigit, ClassSymbol}}, password: "aB3!"},
+		{name: "space is not a symbol", policy: PasswordPolicy{MinLength: 1, Require: []string{ClassSymbol}}, password: "a b", want: []string{"password must contain a symbol"}},
+		{
+			name:     "contains username",
+			policy:   PasswordPolicy{MinLength: 8, ForbidIdentity: true},
+			password: "xxCAROLxx1",
+			want:     []string{"password must not contain your username or email address"},
+		},
+		{
+			name:     "contains email local part",
+			policy:   PasswordPolicy{MinLength: 8, ForbidIdentity: true},
+			password: "c.smith-2024",
+			want:     []string{"password must not contain your username or email address"},
+		},
+		{name: "identity allowed", policy: PasswordPolicy{MinLength: 8}, password: "xxCAROLxx1"},
+		{
+			name:     "every problem at once",
+			policy:   PasswordPolicy{MinLength: 12, Require: []string{ClassDigit}, ForbidIdentity: true},
+			password: "carol",
+			want: []string{
+				"password must be at least 12 characters",
+				"password must contain a digit",
+				"password must not contain your username or email address",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Validate(tt.password, "carol", "c.smith@example.com")
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("Validate = %v, want nil", err)
+				}
+				return
+			}
+			var perr *PasswordError
+			if !errors.As(err, &perr) {
+				t.Fatalf("Validate = %v, want a *PasswordError", err)
+			}
+			if !reflect.DeepEqual(perr.Problems, tt.want) {
+				t.Errorf("problems = %q, want %q", perr.Problems, tt.want)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicyBreached(t *testing.T) {
+	dir := t.TempDir()
+	sum := sha1.Sum([]byte("password1"))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	if err := os.WriteFile(filepath.Join(dir, hash[:5]+".txt"), []byte("0000000000000000000000000000000000A:9\r\n"+hash[5:]+":3\r\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		password string
+		minCount int
+		breached bool
+	}{
+		{"password1", 1, true},
+		{"password1", 3, true},
+		{"password1", 4, false},
+		{"password2", 1, false},
+	}
+	for _, tt := range tests {
+		policy := PasswordPolicy{MinLength: 8, Breached: &BreachedPasswords{Dir: dir, MinCount: tt.minCount}}
+		err := policy.Validate(tt.password, "", "")
+		if got := err != nil; got != tt.breached {
+			t.Errorf("Validate(%q) with MinCount %d = %v, want breached %v", tt.password, tt.minCount, err, tt.breached)
+		}
+	}
+}
+
+func TestNewPasswordPolicy(t *testing.T) {
+	if _, err := NewPasswordPolicy(8, 72, []string{ClassDigit, "emoji"}, true, nil); err == nil {
+		t.Error("unknown class accepted")
+	}
+	p, err := NewPasswordPolicy(8, 72, []string{ClassDigit}, true, nil)
+	if err != nil || p.MaxBytes != 72 || !p.ForbidIdentity {
+		t.Errorf("NewPasswordPolicy = %+v, %v", p, err)
+	}
+}
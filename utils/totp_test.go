@@ -0,0 +1,33 @@
+package utils
+
+import "testing"
+
+func TestNormalizeRecoveryCode(t *testing.T) {
+	for _, code := range []string{
+		"abcde-fghij",
+		"abcdefghij",
+		"ABCDE-FGHIJ",
+		" abcde fghij ",
+		"abcde_fghij",
+		"abcde–fghij", // en dash
+	} {
+		if got := NormalizeRecoveryCode(code); got != "abcdefghij" {
+			t.Errorf("NormalizeRecoveryCode(%q) = %q, want abcdefghij", code, got)
+		}
+	}
+}
+
+func TestGenerateRecoveryCodesAreNormalizable(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("code %q is not formatted as xxxxx-xxxxx", code)
+		}
+		if got := NormalizeRecoveryCode(code); got != code[:5]+code[6:] {
+			t.Errorf("NormalizeRecoveryCode(%q) = %q", code, got)
+		}
+	}
+}
@@ -2,8 +2,11 @@ package utils
 
 import (
 	"errors"
+	"fmt"
+	"net/url"
 	"regexp"
 	"strings"
+	"unicode/utf8"
 )
 
 // emailRegex is a simplified RFC 5322-compatible pattern for common email addresses.
@@ -19,16 +22,32 @@ func ValidateEmail(email string) error {
 	return nil
 }
 
-func ValidatePassword(password string) error {
-	if len(password) < 6 {
-		return errors.New("password must be at least 6 characters")
+func ValidateUsername(username string) error {
+	if len(username) < 3 {
+		return errors.New("username must be at least 3 characters")
 	}
 	return nil
 }
 
-func ValidateUsername(username string) error {
-	if len(username) < 3 {
-		return errors.New("username must be at least 3 characters")
+// ValidateWebsite accepts an empty value or an absolute http(s) URL.
+func ValidateWebsite(website string) error {
+	if website == "" {
+		return nil
+	}
+	if len(website) > 255 {
+		return errors.New("website must be at most 255 characters")
+	}
+	u, err := url.Parse(website)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("website must be an http:// or https:// address")
+	}
+	return nil
+}
+
+// ValidateMaxLength limits value to max characters.
+func ValidateMaxLength(value, field string, max int) error {
+	if utf8.RuneCountInString(value) > max {
+		return fmt.Errorf("%s must be at most %d characters", field, max)
 	}
 	return nil
 }